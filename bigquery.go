@@ -4,17 +4,44 @@ import (
 	"cloud.google.com/go/bigquery"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type BigQueryClient struct {
-	client *bigquery.Client
-	name   string
+	client  *bigquery.Client
+	name    string
+	options BigQueryOptions
+	// sessionID is the BigQuery session used by the multi-statement transaction started by Begin
+	sessionID string
 }
 
+// BigQueryOptions are the cost controls of the queries
+type BigQueryOptions struct {
+	// DryRun only estimates the processed bytes of the queries, without executing them
+	DryRun bool
+	// MaxBytesBilled makes the queries fail which would bill more bytes (0 uses the project default)
+	MaxBytesBilled int64
+	// ConfirmBytes asks for confirmation before executing a query which would process more bytes (0 never asks)
+	ConfirmBytes int64
+	// Location is where the jobs are executed (empty to detect it from the referenced tables)
+	Location string
+	// Dataset is the default dataset of the unqualified table names (dataset or project.dataset)
+	Dataset string
+}
+
+// onDemandPricePerTiB is the on-demand price of the processed bytes (USD / TiB), used for the cost estimation
+const onDemandPricePerTiB = 6.25
+
 func (b *BigQueryClient) ExecuteInTx(ctx context.Context, queries []string) error {
 	for _, query := range queries {
 		err := b.Execute(ctx, query)
@@ -25,31 +52,63 @@ func (b *BigQueryClient) ExecuteInTx(ctx context.Context, queries []string) erro
 	return nil
 }
 
+// ExecuteDDL executes the statements one by one, BigQuery runs DDL as regular queries
+func (b *BigQueryClient) ExecuteDDL(ctx context.Context, statements []string) error {
+	for _, statement := range statements {
+		if err := b.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ DatabaseClient = (*BigQueryClient)(nil)
 
-func NewBigQueryClient(ctx context.Context, projectID string) (*BigQueryClient, error) {
-	client, err := bigquery.NewClient(ctx, projectID)
+func NewBigQueryClient(ctx context.Context, projectID string, options BigQueryOptions, clientOptions ...option.ClientOption) (*BigQueryClient, error) {
+	client, err := bigquery.NewClient(ctx, projectID, clientOptions...)
 	if err != nil {
 		return nil, err
 	}
+	client.Location = options.Location
 
 	return &BigQueryClient{
-		client: client,
-		name:   projectID,
+		client:  client,
+		name:    projectID,
+		options: options,
 	}, nil
 }
 
 func (b *BigQueryClient) Execute(ctx context.Context, query string) error {
+	if b.options.DryRun {
+		return b.dryRun(ctx, query)
+	}
+	if b.options.ConfirmBytes > 0 {
+		if err := b.confirmCost(ctx, query); err != nil {
+			return err
+		}
+		// the statement timeout doesn't include the time of the confirmation
+		ctx = restartStatementTimeout(ctx)
+	}
+
 	writer := GetResultWriter(outputFormat)
+	start := time.Now()
 
-	q := b.client.Query(query)
-	it, err := q.Read(ctx)
+	job, err := b.query(query).Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := waitJob(ctx, job)
+	if err != nil {
+		return err
+	}
+	it, err := job.Read(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Print headers
 	var schema bigquery.Schema
+	var summary StatementSummary
 
 	// Print rows
 	for {
@@ -64,37 +123,185 @@ func (b *BigQueryClient) Execute(ctx context.Context, query string) error {
 
 		if len(schema) == 0 {
 			schema = it.Schema
-			var header []string
-			for _, field := range schema {
-				header = append(header, field.Name)
-			}
-			writer.SetHeader(header)
+			writer.SetHeader(bigQueryHeader(schema))
 		}
 
 		var tableRow []interface{}
 		for i, val := range row {
 			// Check if schema has enough elements to avoid index out of range
 			if i < len(schema) {
-				tableRow = append(tableRow, formatBigQueryValue(val, schema[i].Type))
+				tableRow = append(tableRow, formatBigQueryValue(val, schema[i]))
 			} else {
 				// If schema doesn't have enough elements, just format as string
-				tableRow = append(tableRow, fmt.Sprintf("%v", val))
+				tableRow = append(tableRow, stringify(val))
 			}
 		}
 		writer.AppendRow(tableRow)
+		summary.Rows++
 	}
 
-	writer.Render()
-	fmt.Println()
+	// queries without any row still have the schema
+	if len(schema) == 0 && len(it.Schema) > 0 {
+		schema = it.Schema
+		writer.SetHeader(bigQueryHeader(schema))
+	}
+
+	if status.Statistics != nil {
+		if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok && isBigQueryDML(stats.StatementType) {
+			summary.DML = true
+			summary.Rows = stats.NumDMLAffectedRows
+		} else if ok && stats.DDLOperationPerformed != "" {
+			summary.DDL = true
+			summary.Rows = 1
+		}
+		if showStats {
+			summary.Stats = bigQueryStats(status.Statistics)
+		}
+	}
+
+	summary.Elapsed = time.Since(start)
+	renderResult(writer, len(schema) > 0, summary)
+	return nil
+}
+
+// bigQueryStats returns the processed and billed bytes, the slot time and the cache usage of the query job
+func bigQueryStats(statistics *bigquery.JobStatistics) []QueryStat {
+	stats := []QueryStat{{Name: "bytes processed", Value: formatBytes(statistics.TotalBytesProcessed)}}
+	if details, ok := statistics.Details.(*bigquery.QueryStatistics); ok {
+		stats = append(stats,
+			QueryStat{Name: "bytes billed", Value: formatBytes(details.TotalBytesBilled)},
+			QueryStat{Name: "slot time", Value: fmt.Sprintf("%d ms", details.SlotMillis)},
+			QueryStat{Name: "cache hit", Value: strconv.FormatBool(details.CacheHit)},
+		)
+	}
+	return stats
+}
+
+// byteUnits are the accepted units of parseBytes
+var byteUnits = map[string]int64{
+	"": 1, "B": 1,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12, "PB": 1e15,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40, "PIB": 1 << 50,
+}
+
+// parseBytes parses a size with an optional unit (e.g. 500MB, 10GiB or 1000000)
+func parseBytes(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	i := strings.IndexFunc(size, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i == -1 {
+		i = len(size)
+	}
+	unit, found := byteUnits[strings.ToUpper(strings.TrimSpace(size[i:]))]
+	if !found {
+		return 0, fmt.Errorf("invalid size %q, unknown unit", size)
+	}
+	value, err := strconv.ParseFloat(size[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", size, err)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// formatBytes formats the size with binary units (e.g. 1.5 GiB)
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// estimateBytes returns the number of bytes which would be processed by the query, using a dry run
+func (b *BigQueryClient) estimateBytes(ctx context.Context, query string) (int64, error) {
+	q := b.query(query)
+	q.DryRun = true
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, err
+	}
+	status := job.LastStatus()
+	if status == nil || status.Statistics == nil {
+		return 0, errors.New("dry run returned no statistics")
+	}
+	return status.Statistics.TotalBytesProcessed, nil
+}
+
+// dryRun reports the processed bytes and the estimated cost of the query, without executing it
+func (b *BigQueryClient) dryRun(ctx context.Context, query string) error {
+	bytes, err := b.estimateBytes(ctx, query)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(resultOutput, "Dry run: the query would process %s (estimated cost: %s)\n\n", formatBytes(bytes), formatCost(bytes))
+	return nil
+}
+
+// confirmCost asks for confirmation if the query would process more bytes than the configured threshold
+func (b *BigQueryClient) confirmCost(ctx context.Context, query string) error {
+	bytes, err := b.estimateBytes(ctx, query)
+	if err != nil {
+		return err
+	}
+	if bytes <= b.options.ConfirmBytes {
+		return nil
+	}
+	ok, err := confirm(fmt.Sprintf("The query will process %s (estimated cost: %s). Execute it?", formatBytes(bytes), formatCost(bytes)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("query is not executed")
+	}
 	return nil
 }
 
-func formatBigQueryValue(val interface{}, fieldType bigquery.FieldType) interface{} {
+// formatCost estimates the on-demand cost of processing the bytes
+func formatCost(bytes int64) string {
+	return fmt.Sprintf("$%.2f", float64(bytes)/(1<<40)*onDemandPricePerTiB)
+}
+
+func bigQueryHeader(schema bigquery.Schema) []string {
+	var header []string
+	for _, field := range schema {
+		header = append(header, field.Name)
+	}
+	return header
+}
+
+// isBigQueryDML checks if the statement type reported in the job statistics modifies rows
+func isBigQueryDML(statementType string) bool {
+	switch statementType {
+	case "INSERT", "UPDATE", "DELETE", "MERGE":
+		return true
+	}
+	return false
+}
+
+func formatBigQueryValue(val interface{}, field *bigquery.FieldSchema) interface{} {
 	if val == nil {
-		return "nil"
+		return Null
+	}
+
+	if field.Repeated {
+		values, ok := val.([]bigquery.Value)
+		if !ok {
+			return val
+		}
+		element := *field
+		element.Repeated = false
+		array := make([]interface{}, len(values))
+		for i, v := range values {
+			array[i] = formatBigQueryValue(v, &element)
+		}
+		return array
 	}
 
-	switch fieldType {
+	switch field.Type {
 	case bigquery.StringFieldType:
 		return val
 	case bigquery.BytesFieldType:
@@ -103,14 +310,143 @@ func formatBigQueryValue(val interface{}, fieldType bigquery.FieldType) interfac
 		}
 	case bigquery.TimestampFieldType:
 		if t, ok := val.(time.Time); ok {
-			return t.Format(time.RFC3339)
+			return t.Format(time.RFC3339Nano)
+		}
+	case bigquery.NumericFieldType:
+		if r, ok := val.(*big.Rat); ok {
+			return json.Number(bigquery.NumericString(r))
 		}
+	case bigquery.BigNumericFieldType:
+		if r, ok := val.(*big.Rat); ok {
+			return json.Number(bigquery.BigNumericString(r))
+		}
+	case bigquery.JSONFieldType:
+		if s, ok := val.(string); ok {
+			return JSONValue(s)
+		}
+	case bigquery.RecordFieldType:
+		values, ok := val.([]bigquery.Value)
+		if !ok {
+			return val
+		}
+		record := make(StructValue, len(values))
+		for i, v := range values {
+			if i < len(field.Schema) {
+				record[i] = StructField{Name: field.Schema[i].Name, Value: formatBigQueryValue(v, field.Schema[i])}
+			} else {
+				record[i] = StructField{Value: v}
+			}
+		}
+		return record
 	}
 
 	return val
 }
 
+// query creates a new query with the referenced session variables as parameters, which is executed in the session
+// of the open transaction (if any)
+func (b *BigQueryClient) query(sql string) *bigquery.Query {
+	q := b.client.Query(sql)
+	q.MaxBytesBilled = b.options.MaxBytesBilled
+	q.JobTimeout = statementTimeout
+	if b.options.Dataset != "" {
+		project, dataset, found := strings.Cut(b.options.Dataset, ".")
+		if !found {
+			project, dataset = b.client.Project(), b.options.Dataset
+		}
+		q.DefaultProjectID = project
+		q.DefaultDatasetID = dataset
+	}
+	for name, value := range queryParams(sql) {
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Name: name, Value: value})
+	}
+	if b.sessionID != "" {
+		q.ConnectionProperties = []*bigquery.ConnectionProperty{
+			{Key: "session_id", Value: b.sessionID},
+		}
+	}
+	return q
+}
+
+// run executes a statement without result set and waits until it's finished
+func (b *BigQueryClient) run(ctx context.Context, q *bigquery.Query) (*bigquery.JobStatus, error) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return waitJob(ctx, job)
+}
+
+// waitJob waits until the job is finished. If the context is cancelled (e.g. with Ctrl+C), the job is cancelled
+// too, otherwise it would keep running (and billing) in BigQuery.
+func waitJob(ctx context.Context, job *bigquery.Job) (*bigquery.JobStatus, error) {
+	status, err := job.Wait(ctx)
+	if ctx.Err() != nil {
+		if cancelErr := job.Cancel(context.Background()); cancelErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to cancel job %s: %v\n", job.ID(), cancelErr)
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, status.Err()
+}
+
+func (b *BigQueryClient) Begin(ctx context.Context, readOnly bool) error {
+	if b.sessionID != "" {
+		return errors.New("there is already a transaction in progress")
+	}
+	if readOnly {
+		return errors.New("read-only transactions are not supported by BigQuery")
+	}
+	q := b.query("BEGIN TRANSACTION")
+	q.CreateSession = true
+	status, err := b.run(ctx, q)
+	if err != nil {
+		return err
+	}
+	if status.Statistics == nil || status.Statistics.SessionInfo == nil {
+		return errors.New("BigQuery didn't return the session of the transaction")
+	}
+	b.sessionID = status.Statistics.SessionInfo.SessionID
+	return nil
+}
+
+func (b *BigQueryClient) Commit(ctx context.Context) error {
+	return b.endTransaction(ctx, "COMMIT TRANSACTION")
+}
+
+func (b *BigQueryClient) Rollback(ctx context.Context) error {
+	return b.endTransaction(ctx, "ROLLBACK TRANSACTION")
+}
+
+// endTransaction executes the COMMIT or ROLLBACK statement and terminates the session of the transaction
+func (b *BigQueryClient) endTransaction(ctx context.Context, statement string) error {
+	if b.sessionID == "" {
+		return errors.New("there is no transaction in progress")
+	}
+	_, err := b.run(ctx, b.query(statement))
+	_, abortErr := b.run(ctx, b.query("CALL BQ.ABORT_SESSION()"))
+	b.sessionID = ""
+	if err != nil {
+		return err
+	}
+	return abortErr
+}
+
+func (b *BigQueryClient) TransactionState() TransactionState {
+	if b.sessionID != "" {
+		return TxReadWrite
+	}
+	return TxNone
+}
+
 func (b *BigQueryClient) Close() {
+	if b.sessionID != "" {
+		fmt.Println("Rolling back the open transaction")
+		_ = b.Rollback(context.Background())
+	}
 	b.client.Close()
 }
 
@@ -118,41 +454,329 @@ func (b *BigQueryClient) GetName() string {
 	return b.name
 }
 
-func (b *BigQueryClient) ListTables(ctx context.Context) error {
-	writer := GetResultWriter(outputFormat)
+func (b *BigQueryClient) Dialect(ctx context.Context) Dialect {
+	return GoogleSQL
+}
 
-	// Set up header
-	writer.SetHeader([]string{"Dataset", "Table Name"})
+// ListCatalog lists the schema objects of the given kind, which match the LIKE pattern (all of them if it's empty)
+func (b *BigQueryClient) ListCatalog(ctx context.Context, kind CatalogKind, pattern string) error {
+	if pattern == "" {
+		pattern = "%"
+	}
+	matcher, err := likeToRegexp(pattern)
+	if err != nil {
+		return err
+	}
 
-	// List all datasets
-	datasets := b.client.Datasets(ctx)
+	var header []string
+	var rows [][]interface{}
+	switch kind {
+	case CatalogTables:
+		header = []string{"Dataset", "Table Name"}
+		tables, err := b.tables(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range tables {
+			if matcher.MatchString(tbl.TableID) {
+				rows = append(rows, []interface{}{tbl.DatasetID, tbl.TableID})
+			}
+		}
+	case CatalogSchemas:
+		header = []string{"Dataset"}
+		err = b.eachDataset(ctx, func(dataset *bigquery.Dataset) error {
+			if matcher.MatchString(dataset.DatasetID) {
+				rows = append(rows, []interface{}{dataset.DatasetID})
+			}
+			return nil
+		})
+	case CatalogModels:
+		header = []string{"Dataset", "Model"}
+		err = b.eachDataset(ctx, func(dataset *bigquery.Dataset) error {
+			models := dataset.Models(ctx)
+			for {
+				model, err := models.Next()
+				if errors.Is(err, iterator.Done) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if matcher.MatchString(model.ModelID) {
+					rows = append(rows, []interface{}{dataset.DatasetID, model.ModelID})
+				}
+			}
+		})
+	case CatalogGrants:
+		header = []string{"Dataset", "Role", "Entity"}
+		err = b.eachDataset(ctx, func(dataset *bigquery.Dataset) error {
+			if !matcher.MatchString(dataset.DatasetID) {
+				return nil
+			}
+			metadata, err := dataset.Metadata(ctx)
+			if err != nil {
+				return err
+			}
+			for _, access := range metadata.Access {
+				rows = append(rows, []interface{}{dataset.DatasetID, string(access.Role), accessEntity(access)})
+			}
+			return nil
+		})
+	case CatalogViews:
+		header = []string{"Dataset", "View", "Definition"}
+		rows, err = b.queryEachDataset(ctx, "SELECT table_name, view_definition FROM `%s`.INFORMATION_SCHEMA.VIEWS WHERE table_name LIKE @pattern ORDER BY table_name", pattern)
+	case CatalogIndexes:
+		header = []string{"Dataset", "Table", "Index", "Status"}
+		rows, err = b.queryEachDataset(ctx, "SELECT table_name, index_name, index_status FROM `%s`.INFORMATION_SCHEMA.SEARCH_INDEXES WHERE index_name LIKE @pattern ORDER BY table_name, index_name", pattern)
+	default:
+		return fmt.Errorf("listing %s is not supported by BigQuery", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	renderRows(header, rows)
+	return nil
+}
 
+// eachDataset calls the function with all the datasets of the project
+func (b *BigQueryClient) eachDataset(ctx context.Context, f func(dataset *bigquery.Dataset) error) error {
+	datasets := b.client.Datasets(ctx)
 	for {
 		dataset, err := datasets.Next()
 		if errors.Is(err, iterator.Done) {
-			break
+			return nil
 		}
 		if err != nil {
 			return err
 		}
+		if err := f(dataset); err != nil {
+			return err
+		}
+	}
+}
 
-		// List all tables in the dataset
-		tables := dataset.Tables(ctx)
-
+// queryEachDataset executes the INFORMATION_SCHEMA query (with %s placeholder for the dataset) in all the datasets.
+// The returned rows start with the dataset.
+func (b *BigQueryClient) queryEachDataset(ctx context.Context, sqlTemplate string, pattern string) ([][]interface{}, error) {
+	var rows [][]interface{}
+	err := b.eachDataset(ctx, func(dataset *bigquery.Dataset) error {
+		q := b.client.Query(fmt.Sprintf(sqlTemplate, dataset.DatasetID))
+		q.MaxBytesBilled = b.options.MaxBytesBilled
+		q.JobTimeout = statementTimeout
+		q.Parameters = []bigquery.QueryParameter{{Name: "pattern", Value: pattern}}
+		job, err := q.Run(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := waitJob(ctx, job); err != nil {
+			return err
+		}
+		it, err := job.Read(ctx)
+		if err != nil {
+			return err
+		}
 		for {
-			tbl, err := tables.Next()
+			var row []bigquery.Value
+			err := it.Next(&row)
 			if errors.Is(err, iterator.Done) {
-				break
+				return nil
 			}
 			if err != nil {
 				return err
 			}
+			tableRow := []interface{}{dataset.DatasetID}
+			for i, val := range row {
+				tableRow = append(tableRow, formatBigQueryValue(val, it.Schema[i]))
+			}
+			rows = append(rows, tableRow)
+		}
+	})
+	return rows, err
+}
 
-			writer.AppendRow([]interface{}{dataset.DatasetID, tbl.TableID})
+// accessEntity returns the grantee of a dataset access entry
+func accessEntity(access *bigquery.AccessEntry) string {
+	switch {
+	case access.View != nil:
+		return "view:" + access.View.DatasetID + "." + access.View.TableID
+	case access.Routine != nil:
+		return "routine:" + access.Routine.DatasetID + "." + access.Routine.RoutineID
+	case access.Dataset != nil && access.Dataset.Dataset != nil:
+		return "dataset:" + access.Dataset.Dataset.DatasetID
+	}
+	return access.Entity
+}
+
+// likeToRegexp converts a SQL LIKE pattern (% and _ wildcards) to a regular expression
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
 		}
 	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
 
+// TableNames returns the tables of all the datasets, in the form of dataset.table
+func (b *BigQueryClient) TableNames(ctx context.Context) ([]string, error) {
+	tables, err := b.tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, tbl := range tables {
+		names = append(names, tbl.DatasetID+"."+tbl.TableID)
+	}
+	return names, nil
+}
+
+// ColumnNames returns the top-level fields of the table (dataset.table) schema
+func (b *BigQueryClient) ColumnNames(ctx context.Context, table string) ([]string, error) {
+	tbl, err := b.table(table)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := tbl.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, field := range metadata.Schema {
+		names = append(names, field.Name)
+	}
+	return names, nil
+}
+
+// DescribeTable prints the schema (nested RECORD fields included), the partitioning and the clustering of the table
+func (b *BigQueryClient) DescribeTable(ctx context.Context, table string) error {
+	tbl, err := b.table(table)
+	if err != nil {
+		return err
+	}
+	metadata, err := tbl.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	printSectionTitle("Table")
+	writer := GetResultWriter(outputFormat)
+	writer.SetHeader([]string{"Table", "Type", "Rows", "Bytes", "Partitioning", "Clustering"})
+	clustering := interface{}(Null)
+	if metadata.Clustering != nil {
+		clustering = strings.Join(metadata.Clustering.Fields, ", ")
+	}
+	writer.AppendRow([]interface{}{
+		tbl.DatasetID + "." + tbl.TableID,
+		string(metadata.Type),
+		int64(metadata.NumRows),
+		metadata.NumBytes,
+		describePartitioning(metadata),
+		clustering,
+	})
+	writer.Render()
+	fmt.Fprintln(resultOutput)
+
+	printSectionTitle("Columns")
+	writer = GetResultWriter(outputFormat)
+	writer.SetHeader([]string{"Column", "Type", "Mode", "Default", "Description"})
+	appendSchemaRows(writer, "", metadata.Schema)
 	writer.Render()
-	fmt.Println()
+	fmt.Fprintln(resultOutput)
+
+	if metadata.ViewQuery != "" {
+		printSectionTitle("View Query")
+		fmt.Fprintln(resultOutput, metadata.ViewQuery)
+		fmt.Fprintln(resultOutput)
+	}
 	return nil
 }
+
+// appendSchemaRows adds one row for each field, nested RECORD fields are added as parent.child
+func appendSchemaRows(writer ResultWriter, prefix string, schema bigquery.Schema) {
+	for _, field := range schema {
+		mode := "NULLABLE"
+		if field.Repeated {
+			mode = "REPEATED"
+		} else if field.Required {
+			mode = "REQUIRED"
+		}
+		defaultValue := interface{}(Null)
+		if field.DefaultValueExpression != "" {
+			defaultValue = field.DefaultValueExpression
+		}
+		writer.AppendRow([]interface{}{prefix + field.Name, string(field.Type), mode, defaultValue, field.Description})
+		if field.Type == bigquery.RecordFieldType {
+			appendSchemaRows(writer, prefix+field.Name+".", field.Schema)
+		}
+	}
+}
+
+// describePartitioning returns a short description of the time or range partitioning of the table
+func describePartitioning(metadata *bigquery.TableMetadata) interface{} {
+	switch {
+	case metadata.TimePartitioning != nil:
+		p := metadata.TimePartitioning
+		field := p.Field
+		if field == "" {
+			field = "_PARTITIONTIME"
+		}
+		partitioning := fmt.Sprintf("%s by %s", field, p.Type)
+		if p.Expiration > 0 {
+			partitioning += fmt.Sprintf(", expiration %s", p.Expiration)
+		}
+		if p.RequirePartitionFilter {
+			partitioning += ", filter required"
+		}
+		return partitioning
+	case metadata.RangePartitioning != nil:
+		p := metadata.RangePartitioning
+		if p.Range == nil {
+			return p.Field
+		}
+		return fmt.Sprintf("%s range [%d, %d) interval %d", p.Field, p.Range.Start, p.Range.End, p.Range.Interval)
+	}
+	return Null
+}
+
+// table returns the handle of a table referenced as dataset.table or project.dataset.table
+func (b *BigQueryClient) table(name string) (*bigquery.Table, error) {
+	parts := strings.Split(strings.ReplaceAll(name, "`", ""), ".")
+	switch len(parts) {
+	case 2:
+		return b.client.Dataset(parts[0]).Table(parts[1]), nil
+	case 3:
+		return b.client.DatasetInProject(parts[0], parts[1]).Table(parts[2]), nil
+	}
+	return nil, fmt.Errorf("table should be referenced as dataset.table: %s", name)
+}
+
+// tables lists all tables of all datasets
+func (b *BigQueryClient) tables(ctx context.Context) ([]*bigquery.Table, error) {
+	var result []*bigquery.Table
+	err := b.eachDataset(ctx, func(dataset *bigquery.Dataset) error {
+		// List all tables in the dataset
+		tables := dataset.Tables(ctx)
+
+		for {
+			tbl, err := tables.Next()
+			if errors.Is(err, iterator.Done) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			result = append(result, tbl)
+		}
+	})
+	return result, err
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "0 B", formatBytes(0))
+	require.Equal(t, "1023 B", formatBytes(1023))
+	require.Equal(t, "1.0 KiB", formatBytes(1024))
+	require.Equal(t, "1.5 MiB", formatBytes(1536*1024))
+	require.Equal(t, "2.0 TiB", formatBytes(2<<40))
+}
+
+func TestFormatBigQueryValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	require.Equal(t, "2024-01-02T03:04:05.123456Z", formatBigQueryValue(ts, &bigquery.FieldSchema{Type: bigquery.TimestampFieldType}))
+	require.Equal(t, "0102ff", formatBigQueryValue([]byte{1, 2, 255}, &bigquery.FieldSchema{Type: bigquery.BytesFieldType}))
+	require.Equal(t, Null, formatBigQueryValue(nil, &bigquery.FieldSchema{Type: bigquery.StringFieldType}))
+}
+
+func TestBigQueryQuery(t *testing.T) {
+	defer func() { statementTimeout = 0 }()
+	statementTimeout = time.Minute
+	b, err := NewBigQueryClient(context.Background(), "project", BigQueryOptions{MaxBytesBilled: 1024, Dataset: "other.ds"}, option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer b.Close()
+
+	// every job of the transaction gets the job timeout, BEGIN TRANSACTION included
+	q := b.query("BEGIN TRANSACTION")
+	require.Equal(t, time.Minute, q.JobTimeout)
+	require.Equal(t, int64(1024), q.MaxBytesBilled)
+	require.Equal(t, "other", q.DefaultProjectID)
+	require.Equal(t, "ds", q.DefaultDatasetID)
+	require.Empty(t, q.ConnectionProperties)
+
+	b.sessionID = "session"
+	q = b.query("COMMIT TRANSACTION")
+	require.Equal(t, []*bigquery.ConnectionProperty{{Key: "session_id", Value: "session"}}, q.ConnectionProperties)
+}
+
+func TestBigQueryStats(t *testing.T) {
+	stats := bigQueryStats(&bigquery.JobStatistics{
+		TotalBytesProcessed: 2048,
+		Details: &bigquery.QueryStatistics{
+			TotalBytesBilled: 10 << 20,
+			SlotMillis:       1500,
+			CacheHit:         true,
+		},
+	})
+	require.Equal(t, []QueryStat{
+		{Name: "bytes processed", Value: "2.0 KiB"},
+		{Name: "bytes billed", Value: "10.0 MiB"},
+		{Name: "slot time", Value: "1500 ms"},
+		{Name: "cache hit", Value: "true"},
+	}, stats)
+}
+
+func TestParseBytes(t *testing.T) {
+	checkParse := func(size string, expected int64) {
+		parsed, err := parseBytes(size)
+		require.NoError(t, err, size)
+		require.Equal(t, expected, parsed, size)
+	}
+	checkParse("1000", 1000)
+	checkParse("10 B", 10)
+	checkParse("500MB", 500_000_000)
+	checkParse("10GiB", 10<<30)
+	checkParse("1.5tib", 3<<39)
+
+	_, err := parseBytes("10 parsecs")
+	require.Error(t, err)
+	_, err = parseBytes("GiB")
+	require.Error(t, err)
+}
+
+func TestFormatCost(t *testing.T) {
+	require.Equal(t, "$0.00", formatCost(1<<20))
+	require.Equal(t, "$6.25", formatCost(1<<40))
+	require.Equal(t, "$62.50", formatCost(10<<40))
+}
+
+func TestAppendSchemaRows(t *testing.T) {
+	writer := &VerticalWriter{}
+	appendSchemaRows(writer, "", bigquery.Schema{
+		{Name: "id", Type: bigquery.IntegerFieldType, Required: true},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true, Description: "labels"},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType, DefaultValueExpression: "'Budapest'"},
+			{Name: "geo", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "lat", Type: bigquery.FloatFieldType},
+			}},
+		}},
+	})
+	require.Equal(t, [][]interface{}{
+		{"id", "INTEGER", "REQUIRED", Null, ""},
+		{"tags", "STRING", "REPEATED", Null, "labels"},
+		{"address", "RECORD", "NULLABLE", Null, ""},
+		{"address.city", "STRING", "NULLABLE", "'Budapest'", ""},
+		{"address.geo", "RECORD", "NULLABLE", Null, ""},
+		{"address.geo.lat", "FLOAT", "NULLABLE", Null, ""},
+	}, writer.rows)
+}
+
+func TestDescribePartitioning(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *bigquery.TableMetadata
+		expected interface{}
+	}{
+		{"not partitioned", &bigquery.TableMetadata{}, Null},
+		{"ingestion time", &bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType},
+		}, "_PARTITIONTIME by DAY"},
+		{"column", &bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{
+				Type:                   bigquery.MonthPartitioningType,
+				Field:                  "created",
+				Expiration:             48 * time.Hour,
+				RequirePartitionFilter: true,
+			},
+		}, "created by MONTH, expiration 48h0m0s, filter required"},
+		{"integer range", &bigquery.TableMetadata{
+			RangePartitioning: &bigquery.RangePartitioning{
+				Field: "customer_id",
+				Range: &bigquery.RangePartitioningRange{Start: 0, End: 100, Interval: 10},
+			},
+		}, "customer_id range [0, 100) interval 10"},
+		{"range without bounds", &bigquery.TableMetadata{
+			RangePartitioning: &bigquery.RangePartitioning{Field: "customer_id"},
+		}, "customer_id"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, describePartitioning(test.metadata))
+		})
+	}
+}
+
+func TestLikeToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"%", "", true},
+		{"%", "orders", true},
+		{"order%", "orders", true},
+		{"order%", "customer_orders", false},
+		{"%orders", "customer_orders", true},
+		{"%_orders", "orders", false},
+		{"o_ders", "orders", true},
+		{"o_ders", "oders", false},
+		{"árvíz_", "árvízt", true},
+		{"a.b", "a.b", true},
+		{"a.b", "axb", false},
+		{"t(1)+", "t(1)+", true},
+		{"ORDERS", "orders", false},
+	}
+	for _, test := range tests {
+		matcher, err := likeToRegexp(test.pattern)
+		require.NoError(t, err, test.pattern)
+		require.Equal(t, test.matches, matcher.MatchString(test.value), "%s LIKE %s", test.value, test.pattern)
+	}
+}
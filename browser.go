@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+// maxBrowserColumnWidth limits the width of the columns of the result browser, longer values are truncated
+// (and can be displayed with the detail pane)
+const maxBrowserColumnWidth = 50
+
+// browseResults displays the query results of the interactive console with the result browser (\browse or --browse)
+var browseResults bool
+
+// withBrowser executes f with the result browser as output format, if \browse is on
+func withBrowser(f func() error) error {
+	if !browseResults {
+		return f()
+	}
+	format := outputFormat
+	outputFormat = string(BrowseFormat)
+	defer func() { outputFormat = format }()
+	return f()
+}
+
+// BrowserWriter implements ResultWriter with the full-screen result browser. It falls back to the table format
+// if stdin or stdout is not a terminal.
+type BrowserWriter struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// NewBrowserWriter creates a new BrowserWriter
+func NewBrowserWriter() ResultWriter {
+	return &BrowserWriter{}
+}
+
+func (b *BrowserWriter) SetHeader(columns []string) {
+	b.columns = columns
+}
+
+func (b *BrowserWriter) AppendRow(row []interface{}) {
+	b.rows = append(b.rows, row)
+}
+
+func (b *BrowserWriter) Render() {
+	if len(b.rows) > 0 && term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stdout.Fd()) {
+		_, err := tea.NewProgram(NewResultBrowser(b.columns, b.rows), tea.WithAltScreen()).Run()
+		if err == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Failed to start the result browser: %v\n", err)
+	}
+	table := GetResultWriter(string(TableFormat))
+	table.SetHeader(b.columns)
+	for _, row := range b.rows {
+		table.AppendRow(row)
+	}
+	table.Render()
+}
+
+// ResultBrowser is the model of the full-screen result browser: a scrollable table with frozen header,
+// search, sorting, hidden columns and a detail pane for a single value
+type ResultBrowser struct {
+	columns []string
+	rows    [][]interface{}
+	// cells are the single-line texts of the values
+	cells  [][]string
+	widths []int
+	// order is the displayed order of the rows (indexes of rows), changed by sorting
+	order  []int
+	hidden []bool
+
+	// row (index of order) and col are the position of the cursor
+	row, col int
+	// top and left are the first displayed row and column
+	top, left     int
+	width, height int
+
+	// sortColumn is the column of the sorting, -1 for the original order
+	sortColumn int
+	sortDesc   bool
+
+	// prompt is the kind of the active prompt: / (search), c (jump to column), or 0 if no prompt is active
+	prompt     byte
+	promptText string
+	search     string
+
+	// detail shows the current value in the detail pane, scrolled to the detailTop line
+	detail    bool
+	detailTop int
+	message   string
+}
+
+// NewResultBrowser creates the browser model for a result set
+func NewResultBrowser(columns []string, rows [][]interface{}) *ResultBrowser {
+	b := &ResultBrowser{
+		columns:    make([]string, 0, len(columns)),
+		rows:       rows,
+		cells:      make([][]string, len(rows)),
+		order:      make([]int, len(rows)),
+		width:      80,
+		height:     24,
+		sortColumn: -1,
+	}
+	count := len(columns)
+	for _, row := range rows {
+		count = max(count, len(row))
+	}
+	for i := 0; i < count; i++ {
+		b.columns = append(b.columns, columnName(columns, i))
+		b.widths = append(b.widths, min(ansi.StringWidth(b.columns[i]), maxBrowserColumnWidth))
+	}
+	b.hidden = make([]bool, count)
+	for n, row := range rows {
+		b.order[n] = n
+		b.cells[n] = make([]string, count)
+		for i := 0; i < count; i++ {
+			var val interface{} = Null
+			if i < len(row) && row[i] != nil {
+				val = row[i]
+			}
+			b.cells[n][i] = strings.ReplaceAll(strings.ReplaceAll(stringify(val), "\r", ""), "\n", " ")
+			b.widths[i] = min(max(b.widths[i], ansi.StringWidth(b.cells[n][i])), maxBrowserColumnWidth)
+		}
+	}
+	return b
+}
+
+func (b *ResultBrowser) Init() tea.Cmd {
+	return nil
+}
+
+func (b *ResultBrowser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		b.message = ""
+		switch {
+		case b.prompt != 0:
+			b.updatePrompt(msg)
+		case b.detail:
+			b.updateDetail(msg)
+		default:
+			if msg.String() == "q" || msg.Type == tea.KeyEsc || msg.Type == tea.KeyCtrlC {
+				return b, tea.Quit
+			}
+			b.updateTable(msg)
+		}
+	}
+	b.scroll()
+	return b, nil
+}
+
+// updateTable handles the keys of the table view
+func (b *ResultBrowser) updateTable(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "up", "k":
+		b.row--
+	case "down", "j":
+		b.row++
+	case "left", "h":
+		b.col = b.nextColumn(b.col, -1)
+	case "right", "l":
+		b.col = b.nextColumn(b.col, 1)
+	case "pgup", "ctrl+b":
+		b.row -= b.pageSize()
+	case "pgdown", "ctrl+f", " ":
+		b.row += b.pageSize()
+	case "home", "g":
+		b.row = 0
+	case "end", "G":
+		b.row = len(b.order) - 1
+	case "0", "^":
+		b.col = b.nextColumn(-1, 1)
+	case "$":
+		b.col = b.nextColumn(len(b.columns), -1)
+	case "/", "c":
+		b.prompt = msg.String()[0]
+		b.promptText = ""
+	case "n":
+		b.findNext(1)
+	case "N":
+		b.findNext(-1)
+	case "s":
+		b.sortBy(b.col)
+	case "-":
+		b.hideColumn(b.col)
+	case "+":
+		b.hidden = make([]bool, len(b.columns))
+	case "enter":
+		b.detail = len(b.order) > 0 && len(b.columns) > 0
+		b.detailTop = 0
+	}
+	b.row = max(min(b.row, len(b.order)-1), 0)
+}
+
+// updatePrompt handles the keys while the search or the column prompt is active
+func (b *ResultBrowser) updatePrompt(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		b.promptText += string(msg.Runes)
+	case tea.KeyBackspace:
+		if b.promptText != "" {
+			runes := []rune(b.promptText)
+			b.promptText = string(runes[:len(runes)-1])
+		}
+	case tea.KeyEsc, tea.KeyCtrlC:
+		b.prompt = 0
+	case tea.KeyEnter:
+		prompt := b.prompt
+		b.prompt = 0
+		if prompt == '/' {
+			b.search = b.promptText
+			b.findNext(0)
+		} else {
+			b.jumpToColumn(b.promptText)
+		}
+	}
+}
+
+// updateDetail handles the keys of the detail pane
+func (b *ResultBrowser) updateDetail(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "q", "esc", "enter", "ctrl+c":
+		b.detail = false
+	case "up", "k":
+		b.detailTop--
+	case "down", "j":
+		b.detailTop++
+	case "pgup", "ctrl+b":
+		b.detailTop -= b.height - 2
+	case "pgdown", "ctrl+f", " ":
+		b.detailTop += b.height - 2
+	case "home", "g":
+		b.detailTop = 0
+	}
+	lines := strings.Count(b.detailText(), "\n") + 1
+	b.detailTop = max(min(b.detailTop, lines-(b.height-2)), 0)
+}
+
+// pageSize is the number of the displayed rows (the header, the separator and the status line are not scrolled)
+func (b *ResultBrowser) pageSize() int {
+	return max(b.height-3, 1)
+}
+
+// nextColumn returns the next displayed column after (or before, for negative direction) the given column,
+// or the given column if there is no more
+func (b *ResultBrowser) nextColumn(col int, direction int) int {
+	for i := col + direction; i >= 0 && i < len(b.columns); i += direction {
+		if !b.hidden[i] {
+			return i
+		}
+	}
+	return max(min(col, len(b.columns)-1), 0)
+}
+
+// scroll moves the displayed window to show the cursor
+func (b *ResultBrowser) scroll() {
+	if b.row < b.top {
+		b.top = b.row
+	}
+	if b.row >= b.top+b.pageSize() {
+		b.top = b.row - b.pageSize() + 1
+	}
+	if b.col < b.left {
+		b.left = b.col
+	}
+	for b.left < b.col && !b.columnVisible(b.col) {
+		b.left = b.nextColumn(b.left, 1)
+	}
+}
+
+// columnVisible checks if the column fits the screen when the table is scrolled to the left column
+func (b *ResultBrowser) columnVisible(col int) bool {
+	width := 0
+	for i := b.left; i <= col; i++ {
+		if !b.hidden[i] {
+			width += b.widths[i] + 3
+		}
+	}
+	return width-3 <= b.width
+}
+
+// sortBy sorts the rows by the column: ascending, descending, then back to the original order.
+// The cursor stays on the same row.
+func (b *ResultBrowser) sortBy(col int) {
+	switch {
+	case b.sortColumn != col:
+		b.sortColumn, b.sortDesc = col, false
+	case !b.sortDesc:
+		b.sortDesc = true
+	default:
+		b.sortColumn = -1
+	}
+	current := -1
+	if len(b.order) > 0 {
+		current = b.order[b.row]
+	}
+	for n := range b.order {
+		b.order[n] = n
+	}
+	if b.sortColumn >= 0 {
+		numeric := b.numericColumn(col)
+		sort.SliceStable(b.order, func(i, j int) bool {
+			c := compareValues(b.value(b.order[i], col), b.value(b.order[j], col), numeric)
+			if b.sortDesc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	for n, row := range b.order {
+		if row == current {
+			b.row = n
+		}
+	}
+}
+
+// value returns the value of a row (index of rows) and column, NULL for the missing values
+func (b *ResultBrowser) value(row int, col int) interface{} {
+	if col < len(b.rows[row]) && b.rows[row][col] != nil {
+		return b.rows[row][col]
+	}
+	return Null
+}
+
+// numericColumn checks if all the values of the column are numbers (or NULL)
+func (b *ResultBrowser) numericColumn(col int) bool {
+	for row := range b.rows {
+		val := b.value(row, col)
+		if _, isNull := val.(NullValue); isNull {
+			continue
+		}
+		if _, _, ok := numericValue(val); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares two values for sorting: NULL is the first, the values of numeric columns are compared
+// by value (NaN is before the other numbers), other values by their text
+func compareValues(a interface{}, b interface{}, numeric bool) int {
+	_, aNull := a.(NullValue)
+	_, bNull := b.(NullValue)
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return -1
+	case bNull:
+		return 1
+	}
+	if numeric {
+		x, xNaN, xOk := numericValue(a)
+		y, yNaN, yOk := numericValue(b)
+		switch {
+		case !xOk || !yOk:
+		case xNaN && yNaN:
+			return 0
+		case xNaN:
+			return -1
+		case yNaN:
+			return 1
+		default:
+			return x.Cmp(y)
+		}
+	}
+	return strings.Compare(stringify(a), stringify(b))
+}
+
+// numericValue returns the value as a number, if it's a number (NUMERIC values included). Text is not parsed,
+// as the columns are compared either by value or by text.
+func numericValue(val interface{}) (number *big.Float, nan bool, ok bool) {
+	switch v := val.(type) {
+	case int64:
+		return new(big.Float).SetInt64(v), false, true
+	case int:
+		return new(big.Float).SetInt64(int64(v)), false, true
+	case float64:
+		if math.IsNaN(v) {
+			return nil, true, true
+		}
+		return big.NewFloat(v), false, true
+	case float32:
+		if math.IsNaN(float64(v)) {
+			return nil, true, true
+		}
+		return big.NewFloat(float64(v)), false, true
+	case *big.Rat:
+		return new(big.Float).SetRat(v), false, true
+	case json.Number:
+		if f, ok := new(big.Float).SetString(string(v)); ok {
+			return f, false, true
+		}
+	}
+	return nil, false, false
+}
+
+// findNext moves the cursor to the next (or previous) cell which contains the search text (case-insensitively).
+// Direction 0 starts the search at the current cell.
+func (b *ResultBrowser) findNext(direction int) {
+	if b.search == "" || len(b.order) == 0 {
+		return
+	}
+	search := strings.ToLower(b.search)
+	columns := len(b.columns)
+	total := len(b.order) * columns
+	current := b.row*columns + b.col
+	step := direction
+	if step == 0 {
+		step = 1
+	}
+	for n := 0; n < total; n++ {
+		position := ((current+direction+n*step)%total + total) % total
+		row, col := position/columns, position%columns
+		if !b.hidden[col] && strings.Contains(strings.ToLower(b.cells[b.order[row]][col]), search) {
+			b.row, b.col = row, col
+			return
+		}
+	}
+	b.message = fmt.Sprintf("%q not found", b.search)
+}
+
+// jumpToColumn moves the cursor to the column with the given name: an exact match (case-insensitively),
+// or the first column which starts with or contains the name. Hidden columns are displayed again.
+func (b *ResultBrowser) jumpToColumn(name string) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return
+	}
+	matches := []func(string) bool{
+		func(column string) bool { return column == name },
+		func(column string) bool { return strings.HasPrefix(column, name) },
+		func(column string) bool { return strings.Contains(column, name) },
+	}
+	for _, match := range matches {
+		for i, column := range b.columns {
+			if match(strings.ToLower(column)) {
+				b.hidden[i] = false
+				b.col = i
+				return
+			}
+		}
+	}
+	b.message = fmt.Sprintf("column %q not found", name)
+}
+
+// hideColumn hides the column and moves the cursor to the next displayed column. The last displayed column
+// can't be hidden.
+func (b *ResultBrowser) hideColumn(col int) {
+	next := b.nextColumn(col, 1)
+	if next == col {
+		next = b.nextColumn(col, -1)
+	}
+	if next == col {
+		b.message = "the last column can't be hidden"
+		return
+	}
+	b.hidden[col] = true
+	b.col = next
+	if b.left == col {
+		b.left = next
+	}
+}
+
+// detailText returns the current value for the detail pane. JSON documents, STRUCTs and ARRAYs are pretty-printed.
+func (b *ResultBrowser) detailText() string {
+	if len(b.order) == 0 {
+		return ""
+	}
+	val := b.value(b.order[b.row], b.col)
+	var encoded []byte
+	switch v := val.(type) {
+	case JSONValue:
+		encoded = []byte(v)
+	case string:
+		if trimmed := strings.TrimSpace(v); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			encoded = []byte(v)
+		}
+	case StructValue, []interface{}:
+		encoded = marshalValue(v)
+	}
+	var indented bytes.Buffer
+	if encoded != nil && json.Indent(&indented, encoded, "", "  ") == nil {
+		return indented.String()
+	}
+	return stringify(val)
+}
+
+func (b *ResultBrowser) View() string {
+	var lines []string
+	if b.detail {
+		lines = append(lines, fmt.Sprintf("\x1b[1m%s (row %d)\x1b[0m", b.columns[b.col], b.order[b.row]+1))
+		content := strings.Split(b.detailText(), "\n")
+		lines = append(lines, content[min(b.detailTop, len(content)):min(b.detailTop+b.height-2, len(content))]...)
+		for len(lines) < b.height-1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, b.statusLine("q close, arrows scroll"))
+	} else {
+		var header, separator []string
+		for i := b.left; i < len(b.columns); i++ {
+			if b.hidden[i] {
+				continue
+			}
+			name := b.columns[i]
+			if i == b.sortColumn && b.sortDesc {
+				name += " v"
+			} else if i == b.sortColumn {
+				name += " ^"
+			}
+			header = append(header, "\x1b[1m"+fitCell(name, b.widths[i])+"\x1b[0m")
+			separator = append(separator, strings.Repeat("-", b.widths[i]))
+		}
+		lines = append(lines, strings.Join(header, " | "), strings.Join(separator, "-+-"))
+		for n := b.top; n < min(b.top+b.pageSize(), len(b.order)); n++ {
+			var cells []string
+			for i := b.left; i < len(b.columns); i++ {
+				if b.hidden[i] {
+					continue
+				}
+				cell := fitCell(b.cells[b.order[n]][i], b.widths[i])
+				if n == b.row && i == b.col {
+					cell = "\x1b[7m" + cell + "\x1b[0m"
+				}
+				cells = append(cells, cell)
+			}
+			lines = append(lines, strings.Join(cells, " | "))
+		}
+		for len(lines) < b.height-1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, b.statusLine("q quit, / search, n/N next, c column, s sort, - hide, + show all, enter detail"))
+	}
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, b.width, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statusLine returns the last line: the active prompt, the last message, or the position and the help
+func (b *ResultBrowser) statusLine(help string) string {
+	switch {
+	case b.prompt == '/':
+		return "/" + b.promptText
+	case b.prompt == 'c':
+		return "column: " + b.promptText
+	case b.message != "":
+		return b.message
+	}
+	position := "no rows"
+	if len(b.order) > 0 && len(b.columns) > 0 {
+		position = fmt.Sprintf("row %d/%d, %s", b.row+1, len(b.order), b.columns[b.col])
+	}
+	return "\x1b[7m" + position + "\x1b[0m " + help
+}
+
+// fitCell truncates or pads the text to the width
+func fitCell(text string, width int) string {
+	text = ansi.Truncate(text, width, "…")
+	return text + strings.Repeat(" ", width-ansi.StringWidth(text))
+}
+
+var _ tea.Model = &ResultBrowser{}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultBrowser(t *testing.T) {
+	newBrowser := func() *ResultBrowser {
+		return NewResultBrowser([]string{"id", "name", "doc"}, [][]interface{}{
+			{int64(10), "apple", JSONValue(`{"a":1}`)},
+			{int64(9), "Banana", nil},
+			{int64(100), "cherry", Null},
+		})
+	}
+	press := func(b *ResultBrowser, keys ...string) {
+		for _, key := range keys {
+			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+			switch key {
+			case "enter":
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			case "down":
+				msg = tea.KeyMsg{Type: tea.KeyDown}
+			case "esc":
+				msg = tea.KeyMsg{Type: tea.KeyEsc}
+			}
+			b.Update(msg)
+		}
+	}
+	current := func(b *ResultBrowser) string {
+		return b.cells[b.order[b.row]][b.col]
+	}
+
+	t.Run("sort", func(t *testing.T) {
+		b := newBrowser()
+		press(b, "s")
+		require.Equal(t, []int{1, 0, 2}, b.order)
+		require.Equal(t, "10", current(b))
+		press(b, "s")
+		require.Equal(t, []int{2, 0, 1}, b.order)
+		press(b, "s")
+		require.Equal(t, []int{0, 1, 2}, b.order)
+
+		press(b, "l", "l", "s")
+		require.Equal(t, []int{1, 2, 0}, b.order)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		b := newBrowser()
+		press(b, "/", "b", "a", "n", "enter")
+		require.Equal(t, "Banana", current(b))
+		press(b, "/", "NULL", "enter")
+		require.Equal(t, 1, b.row)
+		require.Equal(t, 2, b.col)
+		press(b, "n")
+		require.Equal(t, 2, b.row)
+		press(b, "N")
+		require.Equal(t, 1, b.row)
+		press(b, "/", "durian", "enter")
+		require.Equal(t, `"durian" not found`, b.message)
+	})
+
+	t.Run("jump to column", func(t *testing.T) {
+		b := newBrowser()
+		press(b, "c", "DO", "enter")
+		require.Equal(t, 2, b.col)
+		press(b, "c", "am", "enter")
+		require.Equal(t, 1, b.col)
+	})
+
+	t.Run("hide columns", func(t *testing.T) {
+		b := newBrowser()
+		press(b, "-")
+		require.Equal(t, []bool{true, false, false}, b.hidden)
+		require.Equal(t, 1, b.col)
+		require.NotContains(t, strings.Split(b.View(), "\n")[0], "id")
+		press(b, "-", "-")
+		require.Equal(t, []bool{true, true, false}, b.hidden)
+		require.Equal(t, "the last column can't be hidden", b.message)
+		press(b, "+")
+		require.Equal(t, []bool{false, false, false}, b.hidden)
+	})
+
+	t.Run("detail", func(t *testing.T) {
+		b := newBrowser()
+		press(b, "$", "enter")
+		require.True(t, b.detail)
+		require.Equal(t, "{\n  \"a\": 1\n}", b.detailText())
+		require.Contains(t, b.View(), "doc (row 1)")
+		press(b, "esc")
+		require.False(t, b.detail)
+	})
+
+	t.Run("view", func(t *testing.T) {
+		b := newBrowser()
+		b.Update(tea.WindowSizeMsg{Width: 30, Height: 5})
+		press(b, "down")
+		lines := strings.Split(b.View(), "\n")
+		require.Len(t, lines, 5)
+		require.Contains(t, lines[0], "name")
+		require.Equal(t, "----+--------+--------", lines[1])
+		require.Contains(t, lines[3], "\x1b[7m9  \x1b[0m")
+		require.Contains(t, lines[4], "row 2/3, id")
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	require.Equal(t, -1, compareValues(Null, int64(1), true))
+	require.Equal(t, -1, compareValues(int64(9), int64(10), true))
+	require.Equal(t, 1, compareValues(json.Number("10.5"), json.Number("9"), true))
+	require.Equal(t, -1, compareValues(math.NaN(), math.Inf(-1), true))
+	require.Equal(t, -1, compareValues("10", "9", false))
+	require.Equal(t, -1, compareValues("B", "a", false))
+	require.Equal(t, 0, compareValues(Null, Null, false))
+}
+
+func TestSortMixedColumn(t *testing.T) {
+	sorted := func(values ...interface{}) []interface{} {
+		var rows [][]interface{}
+		for _, val := range values {
+			rows = append(rows, []interface{}{val})
+		}
+		b := NewResultBrowser([]string{"a"}, rows)
+		b.sortBy(0)
+		var result []interface{}
+		for _, row := range b.order {
+			result = append(result, b.value(row, 0))
+		}
+		return result
+	}
+
+	// strings are compared as text, even if some of them look like numbers
+	require.Equal(t, []interface{}{Null, "10", "9", "9a", "inf", "nan"}, sorted("9a", "nan", "10", Null, "inf", "9"))
+	require.Equal(t, []interface{}{Null, math.Inf(-1), 1.5, int64(2), int64(10)}, sorted(int64(10), 1.5, Null, int64(2), math.Inf(-1)))
+	require.Equal(t, []interface{}{json.Number("9.5"), json.Number("10")}, sorted(json.Number("10"), json.Number("9.5")))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// sqlKeywords are offered by the completion everywhere, except after FROM / JOIN / ... where only tables are expected
+var sqlKeywords = []string{
+	"ALL", "ALTER", "AND", "ARRAY", "AS", "ASC", "BEGIN", "BETWEEN", "BY", "CASE", "CAST", "COMMIT", "COUNT",
+	"CREATE", "CROSS", "DATABASE", "DEFAULT", "DELETE", "DESC", "DISTINCT", "DROP", "ELSE", "END", "EXISTS",
+	"EXPLAIN", "FALSE", "FOREIGN", "FROM", "FULL", "GROUP", "HAVING", "IN", "INDEX", "INNER", "INSERT",
+	"INTERLEAVE", "INTO", "IS", "JOIN", "KEY", "LEFT", "LIKE", "LIMIT", "NOT", "NULL", "OFFSET", "ON", "OR",
+	"ORDER", "OUTER", "PARENT", "PRIMARY", "REFERENCES", "RETURN", "RIGHT", "ROLLBACK", "SELECT", "SET",
+	"STRUCT", "TABLE", "THEN", "TRUE", "UNION", "UNNEST", "UPDATE", "USING", "VALUES", "VIEW", "WHEN",
+	"WHERE", "WITH",
+}
+
+// tableKeywords are followed by a table name
+var tableKeywords = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true,
+}
+
+// Completer suggests SQL keywords, meta-commands, table and column names for the input.
+// The table and column names are loaded on first use, and cached until Invalidate is called.
+type Completer struct {
+	db      DatabaseClient
+	tables  []string
+	columns map[string][]string
+}
+
+// NewCompleter creates a Completer which reads the schema from the database
+func NewCompleter(db DatabaseClient) *Completer {
+	return &Completer{
+		db:      db,
+		columns: map[string][]string{},
+	}
+}
+
+// Invalidate drops the cached schema (e.g. after DDL)
+func (c *Completer) Invalidate() {
+	c.tables = nil
+	c.columns = map[string][]string{}
+}
+
+func (c *Completer) tableNames() []string {
+	if c.tables == nil {
+		tables, err := c.db.TableNames(context.Background())
+		if err != nil {
+			return nil
+		}
+		c.tables = tables
+	}
+	return c.tables
+}
+
+func (c *Completer) columnNames(table string) []string {
+	columns, found := c.columns[table]
+	if !found {
+		columns, _ = c.db.ColumnNames(context.Background(), table)
+		c.columns[table] = columns
+	}
+	return columns
+}
+
+// Complete returns the candidates for the word which ends at pos in the input, and the offset where the word starts
+func (c *Completer) Complete(input string, pos int) (candidates []string, start int) {
+	start = pos
+	for start > 0 && isCompletionChar(input[start-1]) {
+		start--
+	}
+	prefix := input[start:pos]
+
+	// meta-commands are only recognized at the beginning of the input
+	if strings.HasPrefix(prefix, "\\") {
+		if strings.TrimSpace(input[:start]) != "" {
+			return nil, start
+		}
+		return matchPrefix(metaCommandNames(), prefix), start
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(input), "\\") || tableKeywords[strings.ToUpper(previousWord(input[:start]))] {
+		return matchPrefix(c.tableNames(), prefix), start
+	}
+	if prefix == "" {
+		return nil, start
+	}
+
+	var all []string
+	for _, table := range referencedTables(input, c.tableNames()) {
+		all = append(all, c.columnNames(table)...)
+	}
+	all = append(all, c.tableNames()...)
+	for _, keyword := range sqlKeywords {
+		if strings.ToLower(prefix) == prefix {
+			keyword = strings.ToLower(keyword)
+		}
+		all = append(all, keyword)
+	}
+	return matchPrefix(all, prefix), start
+}
+
+// isCompletionChar checks if the character is part of a completed word (identifier, dotted name or meta-command)
+func isCompletionChar(c byte) bool {
+	return isWordChar(c, GoogleSQL) || c == '.' || c == '\\'
+}
+
+// previousWord returns the last word of the text (before the word which is completed)
+func previousWord(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// referencedTables returns the known tables which are used after FROM / JOIN / UPDATE / INTO in the input
+func referencedTables(input string, tables []string) []string {
+	known := map[string]string{}
+	for _, table := range tables {
+		known[strings.ToLower(table)] = table
+	}
+
+	var referenced []string
+	seen := map[string]bool{}
+	afterKeyword := false
+	name := ""
+	addName := func() {
+		if table, ok := known[strings.ToLower(name)]; ok && !seen[table] {
+			referenced = append(referenced, table)
+			seen[table] = true
+		}
+		name = ""
+	}
+	for _, t := range tokenize(input, GoogleSQL) {
+		switch {
+		case t.kind == tokenWord && name == "" && tableKeywords[strings.ToUpper(t.text)]:
+			afterKeyword = true
+		case afterKeyword && (t.kind == tokenWord || t.kind == tokenQuotedIdentifier || t.text == "."):
+			name += strings.Trim(t.text, "`")
+		case afterKeyword && t.kind == tokenWhitespace && name == "":
+		default:
+			if afterKeyword {
+				addName()
+			}
+			afterKeyword = false
+		}
+	}
+	if afterKeyword {
+		addName()
+	}
+	return referenced
+}
+
+// matchPrefix returns the sorted, unique candidates which start with the prefix (case-insensitive)
+func matchPrefix(candidates []string, prefix string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, candidate := range candidates {
+		if seen[candidate] || !strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(prefix)) {
+			continue
+		}
+		seen[candidate] = true
+		result = append(result, candidate)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// commonPrefix returns the longest common prefix of the candidates
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	// the prefix is trimmed by rune, to keep it valid UTF-8
+	prefix := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReferencedTables(t *testing.T) {
+	tables := []string{"Users", "Orders", "ds.events"}
+	require.Equal(t, []string{"Users"}, referencedTables("SELECT * FROM users WHERE ", tables))
+	require.Equal(t, []string{"Users", "Orders"}, referencedTables("SELECT * FROM Users u JOIN Orders o ON ", tables))
+	require.Equal(t, []string{"ds.events"}, referencedTables("SELECT  FROM `ds`.events", tables))
+	require.Equal(t, []string{"Orders"}, referencedTables("UPDATE Orders SET ", tables))
+	require.Empty(t, referencedTables("SELECT * FROM unknown", tables))
+}
+
+func TestMatchPrefix(t *testing.T) {
+	require.Equal(t, []string{"Orders", "order_items"}, matchPrefix([]string{"order_items", "Users", "Orders", "Orders"}, "ord"))
+	require.Equal(t, "Or", commonPrefix([]string{"Orders", "OrderItems", "Or"}))
+	require.Equal(t, "", commonPrefix([]string{"orders", "Orders"}))
+	require.Equal(t, "a", commonPrefix([]string{"aé", "aè"}))
+	require.Equal(t, "árvíz", commonPrefix([]string{"árvíztűrő", "árvíz"}))
+}
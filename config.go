@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named connection with its settings, defined in the config file (or the legacy alias file)
+type Profile struct {
+	// Type is the backend: spanner or bigquery
+	Type string `yaml:"type"`
+	// Connection is the Spanner database (project/instance/database) or the BigQuery project
+	Connection      string        `yaml:"connection"`
+	Format          string        `yaml:"format,omitempty"`
+	Staleness       time.Duration `yaml:"staleness,omitempty"`
+	DatabaseRole    string        `yaml:"database_role,omitempty"`
+	ReadOnly        bool          `yaml:"read_only,omitempty"`
+	PromptColor     string        `yaml:"prompt_color,omitempty"`
+	Location        string        `yaml:"location,omitempty"`
+	Dataset         string        `yaml:"dataset,omitempty"`
+	CredentialsFile string        `yaml:"credentials_file,omitempty"`
+	Emulator        bool          `yaml:"emulator,omitempty"`
+	Endpoint        string        `yaml:"endpoint,omitempty"`
+}
+
+// Config is the content of ~/.config/spanner-console/config.yaml
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// configDir returns the directory of the config and the legacy alias files
+func configDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get home directory")
+	}
+	return filepath.Join(home, ".config", "spanner-console"), nil
+}
+
+// LoadConfig reads the profiles of config.yaml, and the aliases of the legacy alias file (which are used only if
+// there is no profile with the same name). Missing files are ignored.
+func LoadConfig() (*Config, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	content, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config file")
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+
+	aliases, err := readAliasFile(filepath.Join(dir, "alias"))
+	if err != nil {
+		return nil, err
+	}
+	for name, profile := range aliases {
+		if _, found := config.Profiles[name]; !found {
+			config.Profiles[name] = profile
+		}
+	}
+	return config, nil
+}
+
+// readAliasFile reads the legacy alias file, which has "name type connection [read-only]" lines
+func readAliasFile(path string) (map[string]Profile, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open alias file")
+	}
+	defer file.Close()
+
+	profiles := map[string]Profile{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 3 {
+			continue
+		}
+		profile := Profile{Type: parts[1], Connection: parts[2]}
+		for _, option := range parts[3:] {
+			switch option {
+			case "read-only":
+				profile.ReadOnly = true
+			default:
+				// the extra fields were ignored by the earlier versions, they shouldn't make all the aliases unusable
+				fmt.Fprintf(os.Stderr, "Ignoring unknown option %q of alias %q in %s\n", option, parts[0], path)
+			}
+		}
+		// the first definition wins, as before
+		if _, found := profiles[parts[0]]; !found {
+			profiles[parts[0]] = profile
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read alias file")
+	}
+	return profiles, nil
+}
+
+// Profile returns the profile with the given name
+func (c *Config) Profile(name string) (Profile, error) {
+	profile, found := c.Profiles[name]
+	if !found {
+		return Profile{}, errors.Errorf("alias %q not found", name)
+	}
+	return profile, nil
+}
+
+// Names returns the names of the profiles in alphabetical order
+func (c *Config) Names() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigCmd groups the subcommands which display the config
+type ConfigCmd struct {
+	List ConfigListCmd `cmd:"" help:"List the profiles of the config file (and the legacy alias file)"`
+	Show ConfigShowCmd `cmd:"" help:"Show the settings of a profile"`
+}
+
+type ConfigListCmd struct{}
+
+func (c *ConfigListCmd) Run() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	var rows [][]interface{}
+	for _, name := range config.Names() {
+		profile := config.Profiles[name]
+		rows = append(rows, []interface{}{name, profile.Type, profile.Connection})
+	}
+	outputFormat = string(TableFormat)
+	renderRows([]string{"Name", "Type", "Connection"}, rows)
+	return nil
+}
+
+type ConfigShowCmd struct {
+	Alias string `arg:"" help:"Name of the profile"`
+}
+
+func (c *ConfigShowCmd) Run() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	profile, err := config.Profile(c.Alias)
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]Profile{c.Alias: profile}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(encoder.Close())
+}
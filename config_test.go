@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".config", "spanner-console")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	require.Empty(t, config.Names())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`
+profiles:
+  prod:
+    type: spanner
+    connection: p/i/db
+    format: csv
+    staleness: 10s
+    read_only: true
+    prompt_color: red
+  analytics:
+    type: bigquery
+    connection: my-project
+    location: EU
+    dataset: sales
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alias"), []byte(`
+# legacy aliases
+prod spanner other/instance/db
+dev spanner p/i/dev
+dev spanner p/i/ignored
+safe bigquery my-project read-only
+extra spanner p/i/extra description read-only
+`), 0600))
+
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	require.Equal(t, []string{"analytics", "dev", "extra", "prod", "safe"}, config.Names())
+
+	prod, err := config.Profile("prod")
+	require.NoError(t, err)
+	require.Equal(t, Profile{
+		Type:        "spanner",
+		Connection:  "p/i/db",
+		Format:      "csv",
+		Staleness:   10 * time.Second,
+		ReadOnly:    true,
+		PromptColor: "red",
+	}, prod)
+
+	dev, err := config.Profile("dev")
+	require.NoError(t, err)
+	require.Equal(t, Profile{Type: "spanner", Connection: "p/i/dev"}, dev)
+
+	safe, err := config.Profile("safe")
+	require.NoError(t, err)
+	require.True(t, safe.ReadOnly)
+
+	// unknown options are skipped
+	extra, err := config.Profile("extra")
+	require.NoError(t, err)
+	require.Equal(t, Profile{Type: "spanner", Connection: "p/i/extra", ReadOnly: true}, extra)
+
+	_, err = config.Profile("missing")
+	require.Error(t, err)
+}
+
+func TestCliDefaultCommand(t *testing.T) {
+	var cli Cli
+	parser := kong.Must(&cli)
+	ktx, err := parser.Parse([]string{"prod", "-f", "csv"})
+	require.NoError(t, err)
+	require.Equal(t, "console <alias>", ktx.Command())
+	require.Equal(t, "prod", cli.Console.Alias)
+	require.Equal(t, "csv", cli.Console.OutputFormat)
+
+	ktx, err = parser.Parse([]string{"config", "show", "prod"})
+	require.NoError(t, err)
+	require.Equal(t, "config show <alias>", ktx.Command())
+}
+
+func TestSetPromptColor(t *testing.T) {
+	defer setPromptColor("")
+	require.NoError(t, setPromptColor("Red"))
+	require.Equal(t, "\x1b[38;5;1m> \x1b[0m", colorize("> "))
+	require.NoError(t, setPromptColor("208"))
+	require.Equal(t, "\x1b[38;5;208m", promptColor)
+	require.Error(t, setPromptColor("256"))
+	require.Error(t, setPromptColor("purple-ish"))
+	require.NoError(t, setPromptColor(""))
+	require.Equal(t, "> ", colorize("> "))
+}
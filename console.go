@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/pkg/errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func Loop(prompt string, f func(string), db DatabaseClient) error {
-	var history []string
+func Loop(prompt string, f func(string), db DatabaseClient, history *History) error {
+	completer := NewCompleter(db)
 	for {
-		query, stop, err := GetInput(prompt, history)
+		query, stop, err := GetInput(transactionPrompt(prompt, db.TransactionState()), history.Entries(), db.Dialect(context.Background()), completer)
 		if err != nil {
 			return err
 		}
@@ -20,22 +28,486 @@ func Loop(prompt string, f func(string), db DatabaseClient) error {
 		if query == "exit" {
 			return nil
 		}
+		if strings.TrimSpace(query) == "" {
+			continue
+		}
+		if err := history.Add(query); err != nil {
+			fmt.Printf("Failed to save history: %v\n", err)
+		}
 
 		// Handle special commands
-		if query == "\\dt" {
-			err := db.ListTables(context.Background())
-			if err != nil {
-				fmt.Printf("Error listing tables: %v\n", err)
+		if strings.HasPrefix(strings.TrimSpace(query), "\\") {
+			err := withPager(context.Background(), func(ctx context.Context) error {
+				return interruptible(ctx, func(ctx context.Context) error {
+					return runMetaCommand(ctx, db, query)
+				})
+			})
+			if errors.Is(err, errQueryCancelled) {
+				fmt.Println(err)
+			} else if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		} else {
+			dialect := db.Dialect(context.Background())
+			for _, statement := range SplitStatements(query, dialect) {
+				if err := checkReadOnly(statement, dialect); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				if err := confirmDestructive(statement, dialect); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				f(statement)
+				if isDDL(statement, dialect) {
+					completer.Invalidate()
+				}
 			}
+		}
+	}
+}
+
+// metaCommand is a backslash command of the interactive console
+type metaCommand struct {
+	name string
+	// args is the usage of the arguments, displayed by the help
+	args        string
+	description string
+	run         func(ctx context.Context, db DatabaseClient, args []string) error
+}
+
+// metaCommands are the backslash commands, in the order of the \? help
+var metaCommands []metaCommand
+
+func init() {
+	metaCommands = []metaCommand{
+		{name: "\\?", description: "show this help", run: func(ctx context.Context, db DatabaseClient, args []string) error {
+			printMetaCommandHelp()
+			return nil
+		}},
+		{name: "\\d", args: "[table]", description: "describe the table (list the tables without argument)", run: describeCommand},
+		catalogCommand("\\dt", CatalogTables, "list tables"),
+		catalogCommand("\\di", CatalogIndexes, "list indexes"),
+		catalogCommand("\\dv", CatalogViews, "list views"),
+		catalogCommand("\\ds", CatalogSequences, "list sequences"),
+		catalogCommand("\\dcs", CatalogChangeStreams, "list change streams"),
+		catalogCommand("\\dm", CatalogModels, "list models"),
+		catalogCommand("\\dr", CatalogRoles, "list database roles"),
+		catalogCommand("\\dp", CatalogGrants, "list privileges granted on tables (datasets for BigQuery)"),
+		catalogCommand("\\dn", CatalogSchemas, "list named schemas (datasets for BigQuery)"),
+		{name: "\\x", args: "[on|off|auto]", description: "toggle the expanded (vertical) display of the results, auto uses it for results wider than the terminal", run: expandedCommand},
+		toggleCommand("\\browse", &browseResults, "toggle the full-screen browser of the query results"),
+		{name: "\\pager", args: "[on|off|auto]", description: "set when the results are displayed with $PAGER (auto: if they don't fit the terminal)", run: pagerCommand},
+		toggleCommand("\\timing", &showTiming, "toggle the display of the wall time of the statements"),
+		toggleCommand("\\stats", &showStats, "toggle the display of the execution statistics of the statements"),
+		{name: "\\dryrun", args: "[on|off]", description: "toggle the dry run of the BigQuery queries (only the processed bytes and the cost are estimated)", run: dryRunCommand},
+		{name: "\\set", args: "[name [value]]", description: "set a variable, bound to the @name query parameters (list the variables without argument), STATEMENT_TIMEOUT sets the timeout of the statements (e.g. 30s)", run: setCommand},
+		{name: "\\unset", args: "name", description: "remove a variable", run: unsetCommand},
+	}
+}
+
+// catalogCommand creates a meta-command which lists one kind of schema objects, filtered by an optional LIKE pattern
+func catalogCommand(name string, kind CatalogKind, description string) metaCommand {
+	return metaCommand{
+		name:        name,
+		args:        "[pattern]",
+		description: description,
+		run: func(ctx context.Context, db DatabaseClient, args []string) error {
+			if len(args) > 1 {
+				return errors.Errorf("%s accepts only one LIKE pattern", name)
+			}
+			return db.ListCatalog(ctx, kind, strings.Join(args, ""))
+		},
+	}
+}
+
+// toggleCommand creates a meta-command which switches a setting on or off (or toggles it without argument)
+func toggleCommand(name string, setting *bool, description string) metaCommand {
+	return metaCommand{
+		name:        name,
+		args:        "[on|off]",
+		description: description,
+		run: func(ctx context.Context, db DatabaseClient, args []string) error {
+			return toggle(name, setting, args)
+		},
+	}
+}
+
+// toggle switches the setting on or off according to the argument of the meta-command (or toggles it)
+func toggle(name string, setting *bool, args []string) error {
+	switch strings.Join(args, " ") {
+	case "":
+		*setting = !*setting
+	case "on":
+		*setting = true
+	case "off":
+		*setting = false
+	default:
+		return errors.Errorf("%s accepts only on or off", name)
+	}
+	state := "off"
+	if *setting {
+		state = "on"
+	}
+	fmt.Printf("%s is %s\n", strings.TrimPrefix(name, "\\"), state)
+	return nil
+}
+
+// formatBeforeExpanded is the output format restored when the expanded display is turned off
+// (e.g. csv if the console was started with -f csv)
+var formatBeforeExpanded = string(TableFormat)
+
+// setExpanded switches to the vertical or the auto format, and remembers the format which is restored by \x off
+func setExpanded(format OutputFormat) {
+	if current := OutputFormat(outputFormat); current != VerticalFormat && current != AutoFormat {
+		formatBeforeExpanded = outputFormat
+	}
+	outputFormat = string(format)
+}
+
+func expandedCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	switch strings.Join(args, " ") {
+	case "":
+		if OutputFormat(outputFormat) == VerticalFormat {
+			outputFormat = formatBeforeExpanded
 		} else {
-			history = append(history, query)
-			f(query)
+			setExpanded(VerticalFormat)
+		}
+	case "on":
+		setExpanded(VerticalFormat)
+	case "off":
+		if current := OutputFormat(outputFormat); current == VerticalFormat || current == AutoFormat {
+			outputFormat = formatBeforeExpanded
+		}
+	case "auto":
+		setExpanded(AutoFormat)
+	default:
+		return errors.New("\\x accepts only on, off or auto")
+	}
+	state := "off"
+	switch OutputFormat(outputFormat) {
+	case VerticalFormat:
+		state = "on"
+	case AutoFormat:
+		state = "auto"
+	}
+	fmt.Printf("Expanded display is %s\n", state)
+	return nil
+}
+
+func pagerCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	switch mode := PagerMode(strings.Join(args, " ")); mode {
+	case "":
+		if pagerMode == PagerOff {
+			pagerMode = PagerAuto
+		} else {
+			pagerMode = PagerOff
+		}
+	case PagerOn, PagerOff, PagerAuto:
+		pagerMode = mode
+	default:
+		return errors.New("\\pager accepts only on, off or auto")
+	}
+	fmt.Printf("Pager is %s\n", pagerMode)
+	return nil
+}
+
+func dryRunCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	bq, ok := db.(*BigQueryClient)
+	if !ok {
+		return errors.New("dry run is supported only by BigQuery")
+	}
+	return toggle("\\dryrun", &bq.options.DryRun, args)
+}
+
+func describeCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	switch len(args) {
+	case 0:
+		return db.ListCatalog(ctx, CatalogTables, "")
+	case 1:
+		return db.DescribeTable(ctx, strings.Trim(args[0], "`"))
+	}
+	return errors.New("\\d accepts only one table name")
+}
+
+func setCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	switch len(args) {
+	case 0:
+		var rows [][]interface{}
+		for _, name := range sortedVariables() {
+			value := variables[name]
+			rows = append(rows, []interface{}{name, value, paramTypeName(parseParamValue(value))})
+		}
+		renderRows([]string{"Name", "Value", "Type"}, rows)
+		return nil
+	case 1, 2:
+		value := strings.Join(args[1:], "")
+		if strings.EqualFold(args[0], statementTimeoutVariable) {
+			return statementTimeoutCommand(value)
+		}
+		return setVariable(args[0], value)
+	}
+	return errors.New("\\set accepts a name and one value (quote values with spaces)")
+}
+
+// statementTimeoutCommand sets the statement timeout with \set STATEMENT_TIMEOUT (or turns it off with \unset)
+func statementTimeoutCommand(value string) error {
+	if err := setStatementTimeout(value); err != nil {
+		return err
+	}
+	if statementTimeout == 0 {
+		fmt.Println("Statement timeout is off")
+	} else {
+		fmt.Printf("Statement timeout is %s\n", statementTimeout)
+	}
+	return nil
+}
+
+func unsetCommand(ctx context.Context, db DatabaseClient, args []string) error {
+	if len(args) != 1 {
+		return errors.New("\\unset accepts one variable name")
+	}
+	if strings.EqualFold(args[0], statementTimeoutVariable) {
+		return statementTimeoutCommand("")
+	}
+	if _, found := variables[args[0]]; !found {
+		return errors.Errorf("variable %s is not set", args[0])
+	}
+	delete(variables, args[0])
+	return nil
+}
+
+func printMetaCommandHelp() {
+	resultWritten = true
+	for _, command := range metaCommands {
+		fmt.Fprintf(resultOutput, "  %-20s %s\n", strings.TrimSpace(command.name+" "+command.args), command.description)
+	}
+	fmt.Fprintln(resultOutput)
+}
+
+// runMetaCommand executes a backslash command
+func runMetaCommand(ctx context.Context, db DatabaseClient, input string) error {
+	fields := splitArgs(strings.TrimSuffix(strings.TrimSpace(input), ";"))
+	if len(fields) == 0 {
+		return errors.New("empty command")
+	}
+	for _, command := range metaCommands {
+		if command.name == fields[0] {
+			return command.run(ctx, db, fields[1:])
 		}
 	}
+	return errors.Errorf("unknown command %s, use \\? for the list of commands", fields[0])
+}
+
+// metaCommandNames returns the backslash commands, used for completion
+func metaCommandNames() []string {
+	var names []string
+	for _, command := range metaCommands {
+		names = append(names, command.name)
+	}
+	return names
+}
+
+// transactionPrompt decorates the prompt with the state of the open transaction
+func transactionPrompt(prompt string, state TransactionState) string {
+	switch state {
+	case TxReadWrite:
+		return prompt + " (tx)"
+	case TxReadOnly:
+		return prompt + " (ro tx)"
+	}
+	return prompt
+}
+
+// runStatement executes a single statement, handling the transaction control statements
+// (BEGIN [READ ONLY], COMMIT, ROLLBACK) on the client
+func runStatement(ctx context.Context, db DatabaseClient, query string) error {
+	return withPager(ctx, func(ctx context.Context) error {
+		return timed(func() error {
+			return interruptible(ctx, func(ctx context.Context) error {
+				switch command, readOnly := parseTransactionCommand(query); command {
+				case "BEGIN":
+					return db.Begin(ctx, readOnly)
+				case "COMMIT":
+					return db.Commit(ctx)
+				case "ROLLBACK":
+					return db.Rollback(ctx)
+				}
+				return db.Execute(ctx, query)
+			})
+		})
+	})
+}
+
+// errQueryCancelled is returned when the statement is cancelled with Ctrl+C
+var errQueryCancelled = errors.New("query cancelled")
+
+// statementTimeout is the deadline of each statement (--statement-timeout or \set STATEMENT_TIMEOUT), 0 for no limit
+var statementTimeout time.Duration
+
+// statementTimeoutVariable is the name of the \set variable which sets the statement timeout
+const statementTimeoutVariable = "STATEMENT_TIMEOUT"
+
+// setStatementTimeout parses the statement timeout (e.g. 30s, 5m), 0 or empty value turns it off
+func setStatementTimeout(value string) error {
+	timeout := time.Duration(0)
+	if value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout < 0 {
+			return errors.Errorf("invalid statement timeout %q (use e.g. 30s or 5m)", value)
+		}
+	}
+	statementTimeout = timeout
+	return nil
+}
+
+// statementDeadlineKey is the context key of the statementDeadline
+type statementDeadlineKey struct{}
+
+// statementDeadline applies the statement timeout to the context of a statement. The deadline can be restarted,
+// so the time of waiting for a confirmation of the user is not included.
+type statementDeadline struct {
+	// parent is the context without the deadline, which is cancelled by Ctrl+C
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// start returns the context of the statement with a new deadline, and cancels the previous one
+func (d *statementDeadline) start() context.Context {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	ctx, cancel := context.WithTimeout(d.parent, statementTimeout)
+	d.ctx = context.WithValue(ctx, statementDeadlineKey{}, d)
+	d.cancel = cancel
+	return d.ctx
+}
+
+// restartStatementTimeout returns the context of the statement with the deadline restarted from now (e.g. after
+// a confirmation). The context is returned as is if the statement timeout is not set.
+func restartStatementTimeout(ctx context.Context) context.Context {
+	if d, ok := ctx.Value(statementDeadlineKey{}).(*statementDeadline); ok {
+		return d.start()
+	}
+	return ctx
+}
+
+// interruptible executes f with a context which is cancelled by Ctrl+C (SIGINT), so a long-running statement
+// can be cancelled without exiting the console. The context has a deadline if the statement timeout is set.
+func interruptible(ctx context.Context, f func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	statementCtx := ctx
+	var deadline *statementDeadline
+	if statementTimeout > 0 {
+		deadline = &statementDeadline{parent: ctx}
+		statementCtx = deadline.start()
+		defer func() { deadline.cancel() }()
+	}
+	err := f(statementCtx)
+	if deadline != nil {
+		statementCtx = deadline.ctx
+	}
+	var running *runningOperationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &running):
+		// only the wait is cancelled, it's not reported as a cancelled statement
+		return err
+	case errors.Is(statementCtx.Err(), context.DeadlineExceeded):
+		return errors.Errorf("statement timeout (%s) exceeded", statementTimeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return errQueryCancelled
+	}
+	return err
+}
+
+// timed executes the statement(s), and prints the wall time if \timing is on and the execution succeeded
+func timed(execute func() error) error {
+	start := time.Now()
+	if err := execute(); err != nil {
+		return err
+	}
+	printTiming(time.Since(start))
+	return nil
+}
+
+// parseTransactionCommand recognizes the transaction control statements.
+// Returns with the command (BEGIN, COMMIT or ROLLBACK), or empty string for any other statement.
+func parseTransactionCommand(query string) (command string, readOnly bool) {
+	words := strings.Fields(strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(query), ";")))
+	if len(words) > 1 && words[1] == "TRANSACTION" {
+		words = append(words[:1], words[2:]...)
+	}
+	switch strings.Join(words, " ") {
+	case "BEGIN", "START":
+		return "BEGIN", false
+	case "BEGIN READ ONLY", "START READ ONLY":
+		return "BEGIN", true
+	case "BEGIN READ WRITE", "START READ WRITE":
+		return "BEGIN", false
+	case "COMMIT":
+		return "COMMIT", false
+	case "ROLLBACK":
+		return "ROLLBACK", false
+	}
+	return "", false
+}
+
+// readOnly refuses all the statements which may modify the database (--read-only)
+var readOnly bool
+
+// checkReadOnly returns an error if the statement may modify the database in read-only mode.
+// The transaction control statements are accepted, as only the queries can be executed in the transaction.
+func checkReadOnly(statement string, dialect Dialect) error {
+	if command, _ := parseTransactionCommand(statement); command != "" {
+		return nil
+	}
+	if readOnly && !classifyStatement(statement, dialect).isReadOnly() {
+		return errors.New("statement is refused in read-only mode")
+	}
+	return nil
+}
+
+// confirmDestructive asks for confirmation before executing DROP, TRUNCATE, and DELETE / UPDATE without (filtering) WHERE
+func confirmDestructive(statement string, dialect Dialect) error {
+	info := classifyStatement(statement, dialect)
+	if !info.isDestructive() {
+		return nil
+	}
+	question := fmt.Sprintf("%s statement can't be undone. Execute it?", info.keyword)
+	if info.keyword == "DELETE" || info.keyword == "UPDATE" {
+		question = fmt.Sprintf("%s without a filtering WHERE modifies all the rows. Execute it?", info.keyword)
+	}
+	ok, err := confirm(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("statement is not executed")
+	}
+	return nil
+}
+
+// confirm asks a yes / no question on the terminal. It fails if stdin is not a terminal (e.g. piped statements).
+func confirm(question string) (bool, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false, errors.Errorf("%s (confirmation is required, but stdin is not a terminal)", question)
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read the answer")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
 }
 
-func GetInput(prompt string, history []string) (string, bool, error) {
-	app := tea.NewProgram(NewInput(prompt, history))
+func GetInput(prompt string, history []string, dialect Dialect, completer *Completer) (string, bool, error) {
+	app := tea.NewProgram(NewInput(prompt, history, dialect, completer))
 	m, err := app.Run()
 	input := m.(*Input)
 	return input.textinput.Text, input.stop, err
@@ -46,29 +518,129 @@ type Input struct {
 	stop       bool
 	history    []string
 	historyIdx int
+	dialect    Dialect
+	search     *historySearch
+	completer  *Completer
+	// candidates are the completions displayed after an ambiguous Tab
+	candidates []string
+}
+
+// historySearch is the state of the reverse incremental search (Ctrl+R)
+type historySearch struct {
+	query string
+	// match is the index of the current match in the history, -1 if nothing is found
+	match int
+	// original is the input before the search, restored when the search is cancelled
+	original string
+}
+
+// from returns the history index where the search continues after the query is changed: the current match
+// (which may still match), or the newest entry if nothing is found yet
+func (s *historySearch) from(historyLength int) int {
+	if s.match < 0 {
+		return historyLength - 1
+	}
+	return s.match
+}
+
+// promptColor is the ANSI escape sequence of the prompt color (empty for the default color)
+var promptColor string
+
+// promptColors are the named colors accepted by setPromptColor (ANSI 256 color numbers are accepted too)
+var promptColors = map[string]int{
+	"black": 0, "red": 1, "green": 2, "yellow": 3, "blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+}
+
+// setPromptColor sets the color of the prompt, by name (e.g. red) or by ANSI 256 color number (0-255)
+func setPromptColor(color string) error {
+	if color == "" {
+		promptColor = ""
+		return nil
+	}
+	code, found := promptColors[strings.ToLower(color)]
+	if !found {
+		var err error
+		if code, err = strconv.Atoi(color); err != nil || code < 0 || code > 255 {
+			return errors.Errorf("unknown color %q", color)
+		}
+	}
+	promptColor = fmt.Sprintf("\x1b[38;5;%dm", code)
+	return nil
+}
+
+// colorize renders the text with the prompt color
+func colorize(text string) string {
+	if promptColor == "" {
+		return text
+	}
+	return promptColor + text + "\x1b[0m"
 }
 
-func NewInput(prompt string, history []string) *Input {
+func NewInput(prompt string, history []string, dialect Dialect, completer *Completer) *Input {
 	model := NewTextInput()
 	model.Prompt = prompt + "> "
+	model.ContinuationPrompt = colorize(strings.Repeat(" ", max(len(model.Prompt)-3, 0)) + "-> ")
+	model.Prompt = colorize(model.Prompt)
 	model.Width = 30
 	return &Input{
 		textinput:  model,
 		history:    history,
 		historyIdx: -1,
+		dialect:    dialect,
+		completer:  completer,
 	}
 }
 
+// inputComplete checks if the input can be executed: the statements are terminated with
+// a semicolon, or it's a meta-command
+func inputComplete(input string, dialect Dialect) bool {
+	input = strings.TrimSpace(input)
+	if input == "" || input == "exit" || strings.HasPrefix(input, "\\") {
+		return true
+	}
+	statements, rest := splitStatements(input, dialect)
+	if len(statements) == 0 {
+		return false
+	}
+	for _, t := range tokenize(rest, dialect) {
+		if t.unterminated || t.kind != tokenWhitespace && t.kind != tokenComment {
+			return false
+		}
+	}
+	return true
+}
+
 func (i *Input) Init() tea.Cmd {
 	return textinput.Blink
 }
 
 func (i *Input) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && i.search != nil {
+		if handled := i.updateSearch(msg); handled {
+			return i, nil
+		}
+	}
+	if _, ok := msg.(tea.KeyMsg); ok {
+		i.candidates = nil
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.Type {
+		case tea.KeyTab:
+			i.complete()
+			return i, nil
+		case tea.KeyCtrlR:
+			i.search = &historySearch{
+				match:    -1,
+				original: i.textinput.Value(),
+			}
+			return i, nil
 		case tea.KeyEnter:
-			return i, tea.Quit
+			if inputComplete(i.textinput.Value(), i.dialect) {
+				return i, tea.Quit
+			}
+			i.textinput.Insert("\n")
+			return i, nil
 		case tea.KeyCtrlQ:
 			i.stop = true
 			return i, tea.Quit
@@ -84,6 +656,9 @@ func (i *Input) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			i.stop = true
 			return i, tea.Quit
 		case tea.KeyUp:
+			if i.textinput.MoveLine(-1) {
+				return i, nil
+			}
 			if i.historyIdx == -1 {
 				i.historyIdx = len(i.history)
 			}
@@ -94,6 +669,9 @@ func (i *Input) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				i.textinput.SetValue(i.history[i.historyIdx])
 			}
 		case tea.KeyDown:
+			if i.textinput.MoveLine(1) {
+				return i, nil
+			}
 			switch {
 			case i.historyIdx == -1:
 			case i.historyIdx < len(i.history)-1:
@@ -112,7 +690,87 @@ func (i *Input) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return i, cmd
 }
 
+// maxDisplayedCandidates limits the number of completion candidates displayed under the input
+const maxDisplayedCandidates = 100
+
+// complete replaces the word before the cursor with the only candidate, or with the common prefix of the candidates
+// (and displays the candidates)
+func (i *Input) complete() {
+	if i.completer == nil {
+		return
+	}
+	candidates, start := i.completer.Complete(i.textinput.Text, i.textinput.pos)
+	word := i.textinput.Text[start:i.textinput.pos]
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		i.textinput.ReplaceWord(start, candidates[0])
+	default:
+		if prefix := commonPrefix(candidates); len(prefix) > len(word) {
+			i.textinput.ReplaceWord(start, prefix)
+		}
+		i.candidates = candidates
+	}
+}
+
+// updateSearch handles the keys in reverse search mode. Returns false if the key should be handled
+// by the regular input (the search is finished with the current match in that case).
+func (i *Input) updateSearch(msg tea.KeyMsg) bool {
+	s := i.search
+	switch msg.Type {
+	case tea.KeyCtrlR:
+		if s.match > 0 {
+			if older := searchHistory(i.history, s.query, s.match-1); older >= 0 {
+				s.match = older
+			}
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		s.query += string(msg.Runes)
+		s.match = searchHistory(i.history, s.query, s.from(len(i.history)))
+	case tea.KeyBackspace:
+		if runes := []rune(s.query); len(runes) > 0 {
+			s.query = string(runes[:len(runes)-1])
+			s.match = searchHistory(i.history, s.query, s.from(len(i.history)))
+		}
+	case tea.KeyEsc, tea.KeyCtrlG, tea.KeyCtrlC:
+		i.textinput.SetValue(s.original)
+		i.search = nil
+	case tea.KeyEnter:
+		i.acceptSearch()
+	default:
+		i.acceptSearch()
+		return false
+	}
+	return true
+}
+
+// acceptSearch finishes the search, and puts the matching history entry to the input
+func (i *Input) acceptSearch() {
+	if i.search.match >= 0 {
+		i.textinput.SetValue(i.history[i.search.match])
+		i.historyIdx = i.search.match
+	}
+	i.search = nil
+}
+
 func (i *Input) View() string {
+	if i.search != nil {
+		match := ""
+		if i.search.match >= 0 {
+			match = i.history[i.search.match]
+		} else if i.search.query != "" {
+			match = "(no match)"
+		}
+		return fmt.Sprintf("(reverse-i-search)`%s': %s\n", i.search.query, match)
+	}
+	if len(i.candidates) > 0 {
+		candidates := i.candidates
+		if len(candidates) > maxDisplayedCandidates {
+			candidates = append(candidates[:maxDisplayedCandidates:maxDisplayedCandidates], fmt.Sprintf("... (%d more)", len(i.candidates)-maxDisplayedCandidates))
+		}
+		return i.textinput.View() + "\n" + ansi.Wrap(strings.Join(candidates, "  "), i.textinput.Width, " ") + "\n"
+	}
 	return i.textinput.View() + "\n"
 }
 
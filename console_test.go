@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptible(t *testing.T) {
+	err := interruptible(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+		<-ctx.Done()
+		return errors.WithStack(ctx.Err())
+	})
+	require.Equal(t, errQueryCancelled, err)
+
+	running := &runningOperationError{operation: "projects/p/instances/i/databases/d/operations/o"}
+	err = interruptible(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+		<-ctx.Done()
+		return running
+	})
+	require.Equal(t, running, err)
+	require.EqualError(t, err, "stopped waiting for the schema change, it keeps running in the background (operation projects/p/instances/i/databases/d/operations/o)")
+
+	failure := errors.New("syntax error")
+	err = interruptible(context.Background(), func(ctx context.Context) error {
+		return failure
+	})
+	require.Equal(t, failure, err)
+}
+
+func TestInterruptibleTimeout(t *testing.T) {
+	defer func() { statementTimeout = 0 }()
+	statementTimeout = 10 * time.Millisecond
+	err := interruptible(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.WithStack(ctx.Err())
+	})
+	require.EqualError(t, err, "statement timeout (10ms) exceeded")
+}
+
+func TestRestartStatementTimeout(t *testing.T) {
+	defer func() { statementTimeout = 0 }()
+	statementTimeout = 50 * time.Millisecond
+	err := interruptible(context.Background(), func(ctx context.Context) error {
+		// waiting for the confirmation of the user
+		time.Sleep(80 * time.Millisecond)
+		require.Error(t, ctx.Err())
+		ctx = restartStatementTimeout(ctx)
+		require.NoError(t, ctx.Err())
+		<-ctx.Done()
+		return errors.WithStack(ctx.Err())
+	})
+	require.EqualError(t, err, "statement timeout (50ms) exceeded")
+
+	statementTimeout = 0
+	ctx := context.Background()
+	require.Equal(t, ctx, restartStatementTimeout(ctx))
+}
+
+func TestSetStatementTimeout(t *testing.T) {
+	defer func() { statementTimeout = 0 }()
+	require.NoError(t, setStatementTimeout("30s"))
+	require.Equal(t, 30*time.Second, statementTimeout)
+	require.Error(t, setStatementTimeout("30"))
+	require.Error(t, setStatementTimeout("-1s"))
+	require.Equal(t, 30*time.Second, statementTimeout)
+	require.NoError(t, setStatementTimeout(""))
+	require.Equal(t, time.Duration(0), statementTimeout)
+}
+
+func TestParseTransactionCommand(t *testing.T) {
+	tests := []struct {
+		query    string
+		command  string
+		readOnly bool
+	}{
+		{"BEGIN", "BEGIN", false},
+		{"begin;", "BEGIN", false},
+		{"  BEGIN TRANSACTION ; ", "BEGIN", false},
+		{"START TRANSACTION", "BEGIN", false},
+		{"BEGIN READ ONLY", "BEGIN", true},
+		{"start transaction read only;", "BEGIN", true},
+		{"BEGIN READ WRITE", "BEGIN", false},
+		{"START TRANSACTION READ WRITE", "BEGIN", false},
+		{"COMMIT", "COMMIT", false},
+		{"commit transaction;", "COMMIT", false},
+		{"ROLLBACK", "ROLLBACK", false},
+		{"Rollback Transaction", "ROLLBACK", false},
+		{"BEGIN\n  READ\tONLY", "BEGIN", true},
+		{"SELECT 1", "", false},
+		{"BEGIN DELETE FROM t WHERE true; END", "", false},
+		{"COMMIT WORK", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		command, readOnly := parseTransactionCommand(test.query)
+		require.Equal(t, test.command, command, test.query)
+		require.Equal(t, test.readOnly, readOnly, test.query)
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	input := NewInput("test", []string{"SELECT 'árvíztűrő';", "select * from users;", "select 1;", "SELECT * FROM orders;"}, GoogleSQL, nil)
+	typeKeys := func(keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			input.Update(key)
+		}
+	}
+	runes := func(text string) tea.KeyMsg {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)}
+	}
+
+	typeKeys(tea.KeyMsg{Type: tea.KeyCtrlR}, runes("sel"))
+	require.Equal(t, 3, input.search.match)
+
+	// typing continues from the current match, it doesn't jump back to the newest entry
+	typeKeys(tea.KeyMsg{Type: tea.KeyCtrlR}, tea.KeyMsg{Type: tea.KeyCtrlR}, runes("e"))
+	require.Equal(t, 1, input.search.match)
+	typeKeys(runes("ct '"))
+	require.Equal(t, 0, input.search.match)
+
+	// backspace removes the last character, not the last byte
+	typeKeys(tea.KeyMsg{Type: tea.KeyEsc}, tea.KeyMsg{Type: tea.KeyCtrlR}, runes("tűr"), tea.KeyMsg{Type: tea.KeyBackspace})
+	require.Equal(t, "tű", input.search.query)
+	require.Equal(t, 0, input.search.match)
+
+	typeKeys(tea.KeyMsg{Type: tea.KeyEnter})
+	require.Nil(t, input.search)
+	require.Equal(t, "SELECT 'árvíztűrő';", input.textinput.Value())
+}
+
+// catalogRecorder records the catalog calls of the meta-commands
+type catalogRecorder struct {
+	DatabaseClient
+	calls []string
+}
+
+func (c *catalogRecorder) ListCatalog(ctx context.Context, kind CatalogKind, pattern string) error {
+	c.calls = append(c.calls, "list "+string(kind)+" "+pattern)
+	return nil
+}
+
+func (c *catalogRecorder) DescribeTable(ctx context.Context, table string) error {
+	c.calls = append(c.calls, "describe "+table)
+	return nil
+}
+
+func TestRunMetaCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"\\d", "list tables ", ""},
+		{"\\d Singers", "describe Singers", ""},
+		{"  \\d `Singers`;", "describe Singers", ""},
+		{"\\dt", "list tables ", ""},
+		{"\\dt sing%", "list tables sing%", ""},
+		{"\\di idx_%", "list indexes idx_%", ""},
+		{"\\dv", "list views ", ""},
+		{"\\ds", "list sequences ", ""},
+		{"\\dcs", "list change streams ", ""},
+		{"\\dm", "list models ", ""},
+		{"\\dr", "list roles ", ""},
+		{"\\dp", "list grants ", ""},
+		{"\\dn", "list schemas ", ""},
+		{"\\d a b", "", "\\d accepts only one table name"},
+		{"\\dt a b", "", "\\dt accepts only one LIKE pattern"},
+		{"\\dx", "", "unknown command \\dx, use \\? for the list of commands"},
+	}
+	for _, test := range tests {
+		db := &catalogRecorder{}
+		err := runMetaCommand(context.Background(), db, test.input)
+		if test.err != "" {
+			require.EqualError(t, err, test.err, test.input)
+			require.Empty(t, db.calls, test.input)
+			continue
+		}
+		require.NoError(t, err, test.input)
+		require.Equal(t, []string{test.expected}, db.calls, test.input)
+	}
+}
+
+func TestExpandedCommand(t *testing.T) {
+	defer func() {
+		outputFormat = ""
+		formatBeforeExpanded = string(TableFormat)
+	}()
+	expanded := func(args ...string) string {
+		require.NoError(t, expandedCommand(context.Background(), nil, args))
+		return outputFormat
+	}
+
+	outputFormat = string(CSVFormat)
+	require.Equal(t, "vertical", expanded())
+	require.Equal(t, "csv", expanded())
+	require.Equal(t, "auto", expanded("auto"))
+	require.Equal(t, "vertical", expanded("on"))
+	require.Equal(t, "csv", expanded("off"))
+	require.Equal(t, "csv", expanded("off"))
+
+	outputFormat = string(JSONFormat)
+	require.Equal(t, "vertical", expanded("on"))
+	require.Equal(t, "json", expanded())
+
+	// started with -f vertical
+	formatBeforeExpanded = string(TableFormat)
+	outputFormat = string(VerticalFormat)
+	require.Equal(t, "table", expanded("off"))
+
+	require.Error(t, expandedCommand(context.Background(), nil, []string{"maybe"}))
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"io"
+	"math"
 	"os"
+	"strings"
+	"time"
 )
 
 // OutputFormat represents the format for query results
@@ -16,8 +25,36 @@ const (
 	TableFormat OutputFormat = "table"
 	// CSVFormat represents the CSV output format
 	CSVFormat OutputFormat = "csv"
+	// JSONFormat represents the JSON output format (one array of objects per result)
+	JSONFormat OutputFormat = "json"
+	// JSONLFormat represents the JSON Lines output format (one object per row)
+	JSONLFormat OutputFormat = "jsonl"
+	// VerticalFormat represents the expanded output format (one block of column | value lines per row)
+	VerticalFormat OutputFormat = "vertical"
+	// AutoFormat represents the table format, switching to vertical when the table is wider than the terminal
+	AutoFormat OutputFormat = "auto"
+	// BrowseFormat represents the full-screen result browser of the interactive console (enabled by \browse)
+	BrowseFormat OutputFormat = "browse"
 )
 
+// isOutputFormat checks if the format is one of the supported output formats
+func isOutputFormat(format string) bool {
+	switch OutputFormat(format) {
+	case TableFormat, CSVFormat, JSONFormat, JSONLFormat, VerticalFormat, AutoFormat:
+		return true
+	}
+	return false
+}
+
+// isHumanReadableFormat checks if the format is for reading on the terminal (not for processing by other tools)
+func isHumanReadableFormat(format string) bool {
+	switch OutputFormat(format) {
+	case TableFormat, VerticalFormat, AutoFormat, BrowseFormat:
+		return true
+	}
+	return false
+}
+
 // ResultWriter interface for writing query results
 type ResultWriter interface {
 	SetHeader(columns []string)
@@ -25,15 +62,30 @@ type ResultWriter interface {
 	Render()
 }
 
+// tableBatchSize renders the tables in batches of rows (--batch-size), 0 renders the whole table at once
+var tableBatchSize int
+
 // TableWriter implements ResultWriter using table format
 type TableWriter struct {
 	writer table.Writer
+	// batchSize > 0 renders the rows in batches, with the column widths of the first batch, instead of
+	// collecting all the rows before rendering
+	batchSize int
+	header    table.Row
+	batch     []table.Row
+	// widths are the column widths of the first batch, used by all the following batches
+	widths []int
+	// bottom is the bottom border of the table, written after the last batch
+	bottom string
 }
 
 // NewTableWriter creates a new TableWriter
 func NewTableWriter() ResultWriter {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	if tableBatchSize > 0 {
+		return &TableWriter{writer: t, batchSize: tableBatchSize}
+	}
+	t.SetOutputMirror(resultOutput)
 	return &TableWriter{writer: t}
 }
 
@@ -42,19 +94,213 @@ func (t *TableWriter) SetHeader(columns []string) {
 	for i, col := range columns {
 		header[i] = col
 	}
+	if t.batchSize > 0 {
+		t.header = header
+		return
+	}
 	t.writer.AppendHeader(header)
 }
 
 func (t *TableWriter) AppendRow(row []interface{}) {
 	tableRow := make(table.Row, len(row))
 	for i, val := range row {
-		tableRow[i] = val
+		if val == nil {
+			tableRow[i] = Null
+		} else {
+			tableRow[i] = val
+		}
+	}
+	if t.batchSize > 0 {
+		t.batch = append(t.batch, tableRow)
+		if len(t.batch) == t.batchSize {
+			t.renderBatch()
+		}
+		return
 	}
 	t.writer.AppendRow(tableRow)
 }
 
 func (t *TableWriter) Render() {
-	t.writer.Render()
+	if t.batchSize == 0 {
+		t.writer.Render()
+		return
+	}
+	if t.widths == nil {
+		// the whole result fits in one batch, it's rendered as a regular table
+		t.writer.SetOutputMirror(resultOutput)
+		t.writer.AppendHeader(t.header)
+		t.writer.AppendRows(t.batch)
+		t.writer.Render()
+		return
+	}
+	if len(t.batch) > 0 {
+		t.renderBatch()
+	}
+	fmt.Fprintln(resultOutput, t.bottom)
+}
+
+// renderBatch writes the collected rows as a part of the table: the first batch with the header, the following
+// batches without the borders, all of them with the column widths of the first batch
+func (t *TableWriter) renderBatch() {
+	first := t.widths == nil
+	if first {
+		t.widths = tableColumnWidths(t.header, t.batch)
+	}
+	writer := table.NewWriter()
+	var configs []table.ColumnConfig
+	for i, width := range t.widths {
+		// 0 would mean unlimited width for an empty column (e.g. SELECT ''), the following batches would be misaligned
+		width = max(width, 1)
+		configs = append(configs, table.ColumnConfig{Number: i + 1, WidthMin: width, WidthMax: width})
+	}
+	writer.SetColumnConfigs(configs)
+	if first {
+		writer.AppendHeader(t.header)
+	}
+	writer.AppendRows(t.batch)
+	lines := strings.Split(writer.Render(), "\n")
+	t.bottom = lines[len(lines)-1]
+	lines = lines[:len(lines)-1]
+	if !first {
+		lines = lines[1:]
+	}
+	fmt.Fprintln(resultOutput, strings.Join(lines, "\n"))
+	t.batch = t.batch[:0]
+}
+
+// tableColumnWidths returns the display widths of the columns: the longest line of the header and the values
+func tableColumnWidths(header table.Row, rows []table.Row) []int {
+	var widths []int
+	measure := func(row table.Row) {
+		for i, val := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], maxLineWidth(stringify(val)))
+		}
+	}
+	measure(header)
+	for _, row := range rows {
+		measure(row)
+	}
+	return widths
+}
+
+// VerticalWriter implements ResultWriter using the expanded format: a -[ RECORD n ]- block per row,
+// with a column | value line per column
+type VerticalWriter struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// NewVerticalWriter creates a new VerticalWriter
+func NewVerticalWriter() ResultWriter {
+	return &VerticalWriter{}
+}
+
+func (v *VerticalWriter) SetHeader(columns []string) {
+	v.columns = columns
+}
+
+func (v *VerticalWriter) AppendRow(row []interface{}) {
+	v.rows = append(v.rows, row)
+}
+
+func (v *VerticalWriter) Render() {
+	out := bufio.NewWriter(resultOutput)
+	defer out.Flush()
+
+	// the widths are the same for all the records, to align the separators
+	keyWidth, valueWidth := 0, 0
+	values := make([][]string, len(v.rows))
+	for n, row := range v.rows {
+		values[n] = make([]string, len(row))
+		for i, val := range row {
+			if val == nil {
+				val = Null
+			}
+			values[n][i] = stringify(val)
+			keyWidth = max(keyWidth, ansi.StringWidth(columnName(v.columns, i)))
+			valueWidth = max(valueWidth, maxLineWidth(values[n][i]))
+		}
+	}
+
+	for n, row := range values {
+		out.WriteString(recordSeparator(n+1, keyWidth, valueWidth))
+		out.WriteString("\n")
+		for i, value := range row {
+			for j, line := range strings.Split(value, "\n") {
+				key := ""
+				if j == 0 {
+					key = columnName(v.columns, i)
+				}
+				out.WriteString(key + strings.Repeat(" ", keyWidth-ansi.StringWidth(key)) + " | " + line + "\n")
+			}
+		}
+	}
+}
+
+// recordSeparator returns the -[ RECORD n ]--+---- line of the vertical format, the + is above the column separator
+func recordSeparator(n int, keyWidth int, valueWidth int) string {
+	label := fmt.Sprintf("-[ RECORD %d ]", n)
+	line := []byte(strings.Repeat("-", keyWidth+3+valueWidth))
+	line[keyWidth+1] = '+'
+	if len(label) >= len(line) {
+		return label
+	}
+	return label + string(line[len(label):])
+}
+
+// AutoWriter implements ResultWriter using the table format, or the vertical format if the table is wider than
+// the terminal
+type AutoWriter struct {
+	table    table.Writer
+	vertical *VerticalWriter
+}
+
+// NewAutoWriter creates a new AutoWriter
+func NewAutoWriter() ResultWriter {
+	return &AutoWriter{
+		table:    table.NewWriter(),
+		vertical: &VerticalWriter{},
+	}
+}
+
+func (a *AutoWriter) SetHeader(columns []string) {
+	(&TableWriter{writer: a.table}).SetHeader(columns)
+	a.vertical.SetHeader(columns)
+}
+
+func (a *AutoWriter) AppendRow(row []interface{}) {
+	(&TableWriter{writer: a.table}).AppendRow(row)
+	a.vertical.AppendRow(row)
+}
+
+func (a *AutoWriter) Render() {
+	rendered := a.table.Render()
+	if width := terminalWidth(); width > 0 && maxLineWidth(rendered) > width {
+		a.vertical.Render()
+		return
+	}
+	fmt.Fprintln(resultOutput, rendered)
+}
+
+// terminalWidth returns the width of the terminal, or 0 if stdout is not a terminal
+func terminalWidth() int {
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// maxLineWidth returns the display width of the longest line of the text
+func maxLineWidth(text string) int {
+	width := 0
+	for _, line := range strings.Split(text, "\n") {
+		width = max(width, ansi.StringWidth(line))
+	}
+	return width
 }
 
 // CSVWriter implements ResultWriter using CSV format
@@ -65,7 +311,7 @@ type CSVWriter struct {
 // NewCSVWriter creates a new CSVWriter
 func NewCSVWriter() ResultWriter {
 	return &CSVWriter{
-		writer: csv.NewWriter(os.Stdout),
+		writer: csv.NewWriter(resultOutput),
 	}
 }
 
@@ -76,7 +322,7 @@ func (c *CSVWriter) SetHeader(columns []string) {
 func (c *CSVWriter) AppendRow(row []interface{}) {
 	strRow := make([]string, len(row))
 	for i, val := range row {
-		if val == nil {
+		if val == nil || val == Null {
 			strRow[i] = ""
 		} else {
 			strRow[i] = stringify(val)
@@ -89,6 +335,208 @@ func (c *CSVWriter) Render() {
 	c.writer.Flush()
 }
 
+// JSONWriter implements ResultWriter, writing the result as a single JSON array of objects. The rows are written
+// as they arrive, Render closes the array.
+type JSONWriter struct {
+	columns []string
+	out     *bufio.Writer
+	rows    int
+}
+
+// NewJSONWriter creates a new JSONWriter
+func NewJSONWriter() ResultWriter {
+	return &JSONWriter{
+		out: bufio.NewWriter(resultOutput),
+	}
+}
+
+func (j *JSONWriter) SetHeader(columns []string) {
+	j.columns = columns
+}
+
+func (j *JSONWriter) AppendRow(row []interface{}) {
+	if j.rows == 0 {
+		j.out.WriteString("[")
+	} else {
+		j.out.WriteString(",")
+	}
+	j.out.WriteString("\n  ")
+	j.out.Write(marshalRow(j.columns, row))
+	j.rows++
+}
+
+func (j *JSONWriter) Render() {
+	if j.rows == 0 {
+		j.out.WriteString("[")
+	} else {
+		j.out.WriteString("\n")
+	}
+	j.out.WriteString("]\n")
+	j.out.Flush()
+}
+
+// JSONLWriter implements ResultWriter, writing one JSON object per line
+type JSONLWriter struct {
+	columns []string
+	out     *bufio.Writer
+}
+
+// NewJSONLWriter creates a new JSONLWriter
+func NewJSONLWriter() ResultWriter {
+	return &JSONLWriter{
+		out: bufio.NewWriter(resultOutput),
+	}
+}
+
+func (j *JSONLWriter) SetHeader(columns []string) {
+	j.columns = columns
+}
+
+func (j *JSONLWriter) AppendRow(row []interface{}) {
+	j.out.Write(marshalRow(j.columns, row))
+	j.out.WriteString("\n")
+}
+
+func (j *JSONLWriter) Render() {
+	j.out.Flush()
+}
+
+// marshalRow encodes a row as a JSON object, keeping the column order of the result
+func marshalRow(columns []string, row []interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	keys := jsonKeys(columns, len(row))
+	for i, val := range row {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(keys[i])
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(marshalValue(val))
+	}
+	buf.WriteString("}")
+	return buf.Bytes()
+}
+
+// columnName returns the name of the i-th column, or _i for the unnamed columns (e.g. SELECT 1)
+func columnName(columns []string, i int) string {
+	if i < len(columns) && columns[i] != "" {
+		return columns[i]
+	}
+	return fmt.Sprintf("_%d", i)
+}
+
+// jsonKeys returns the keys of the JSON object of a row. The duplicated column names (e.g. SELECT a.id, b.id) get
+// a numeric suffix (id, id_1), as the JSON tools keep only one of the repeated keys.
+func jsonKeys(columns []string, n int) []string {
+	keys := make([]string, n)
+	used := make(map[string]bool, n)
+	for i := range keys {
+		name := columnName(columns, i)
+		key := name
+		for suffix := 1; used[key]; suffix++ {
+			key = fmt.Sprintf("%s_%d", name, suffix)
+		}
+		used[key] = true
+		keys[i] = key
+	}
+	return keys
+}
+
+// marshalValue encodes a single result value as JSON, falling back to a JSON string
+// for values encoding/json can't represent (NaN, infinity)
+func marshalValue(val interface{}) []byte {
+	encoded, err := json.Marshal(jsonValue(val))
+	if err != nil {
+		encoded, _ = json.Marshal(stringify(val))
+	}
+	return encoded
+}
+
+// jsonValue replaces floats which are not valid JSON numbers with their string representation
+func jsonValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return stringify(v)
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return stringify(v)
+		}
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted[i] = jsonValue(elem)
+		}
+		return converted
+	}
+	return val
+}
+
+// NullValue represents a SQL NULL in a result row
+type NullValue struct{}
+
+// Null is the value used for NULL columns in result rows, regardless of the column type
+var Null = NullValue{}
+
+// nullDisplay is the string used to render NULL in table output
+var nullDisplay = "NULL"
+
+func (NullValue) String() string {
+	return nullDisplay
+}
+
+func (NullValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// StructField is a single named field of a StructValue
+type StructField struct {
+	Name  string
+	Value interface{}
+}
+
+// StructValue represents a STRUCT (Spanner) or RECORD (BigQuery) value, keeping the field order
+type StructValue []StructField
+
+func (s StructValue) MarshalJSON() ([]byte, error) {
+	columns := make([]string, len(s))
+	values := make([]interface{}, len(s))
+	for i, field := range s {
+		columns[i] = field.Name
+		values[i] = field.Value
+	}
+	return marshalRow(columns, values), nil
+}
+
+func (s StructValue) String() string {
+	var fields []string
+	for _, field := range s {
+		if field.Name == "" {
+			fields = append(fields, stringify(field.Value))
+		} else {
+			fields = append(fields, field.Name+": "+stringify(field.Value))
+		}
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// JSONValue is a JSON document stored in the database. It's embedded as is in JSON output.
+type JSONValue string
+
+func (j JSONValue) MarshalJSON() ([]byte, error) {
+	if !json.Valid([]byte(j)) {
+		return json.Marshal(string(j))
+	}
+	return []byte(j), nil
+}
+
+func (j JSONValue) String() string {
+	return string(j)
+}
+
 // stringify converts any value to a string representation
 func stringify(val interface{}) string {
 	if val == nil {
@@ -97,14 +545,176 @@ func stringify(val interface{}) string {
 	return fmt.Sprintf("%v", val)
 }
 
+// StatementSummary is reported after each statement
+type StatementSummary struct {
+	// DML is true when Rows is the number of rows modified by INSERT / UPDATE / DELETE
+	DML bool
+	// DDL is true when Rows is the number of executed schema changes
+	DDL bool
+	// Rows is the number of returned (query) or affected (DML) rows, or executed (DDL) statements
+	Rows int64
+	// Elapsed is the wall time of the statement
+	Elapsed time.Duration
+	// Stats are the execution statistics, collected when \stats is on
+	Stats []QueryStat
+}
+
+// QueryStat is one execution statistic of a statement (e.g. CPU time or bytes processed)
+type QueryStat struct {
+	Name  string
+	Value string
+}
+
+func (s StatementSummary) String() string {
+	rows := fmt.Sprintf("%d rows", s.Rows)
+	if s.Rows == 1 {
+		rows = "1 row"
+	}
+	if s.DML {
+		return fmt.Sprintf("%s affected (%s)", rows, s.Elapsed.Round(time.Millisecond))
+	}
+	if s.DDL {
+		statements := fmt.Sprintf("%d DDL statements", s.Rows)
+		if s.Rows == 1 {
+			statements = "1 DDL statement"
+		}
+		return fmt.Sprintf("%s executed (%s)", statements, s.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s in set (%s)", rows, s.Elapsed.Round(time.Millisecond))
+}
+
+// printSummary writes the summary of a statement. For the machine-readable formats it's written
+// to stderr to keep stdout parseable.
+func printSummary(summary StatementSummary) {
+	if isHumanReadableFormat(outputFormat) {
+		fmt.Fprintln(resultOutput, summary)
+		return
+	}
+	fmt.Fprintln(os.Stderr, summary)
+}
+
+// showTiming and showStats are toggled by \timing and \stats (or --timing and --stats)
+var showTiming, showStats bool
+
+// resultOutput receives the rendered results: stdout, or a buffer which is displayed by the pager
+var resultOutput io.Writer = os.Stdout
+
+// infoToResult sends the timing and the statistics to the result output (in the interactive console).
+// Otherwise they are written to stderr to keep the output parseable.
+var infoToResult bool
+
+// infoOutput returns the writer of the timing and the statistics
+func infoOutput() io.Writer {
+	if infoToResult {
+		return resultOutput
+	}
+	return os.Stderr
+}
+
+// renderResult prints the result set (if any) and the summary of the statement
+func renderResult(writer ResultWriter, hasResult bool, summary StatementSummary) {
+	if hasResult {
+		writer.Render()
+	}
+	printSummary(summary)
+	printStats(summary.Stats)
+	fmt.Fprintln(resultOutput)
+}
+
+// printStats writes the execution statistics of a statement in one line
+func printStats(stats []QueryStat) {
+	if len(stats) == 0 {
+		return
+	}
+	var parts []string
+	for _, stat := range stats {
+		parts = append(parts, stat.Name+": "+stat.Value)
+	}
+	fmt.Fprintf(infoOutput(), "Statistics: %s\n", strings.Join(parts, ", "))
+}
+
+// printTiming writes the wall time of a statement, if \timing is on
+func printTiming(elapsed time.Duration) {
+	if showTiming {
+		fmt.Fprintf(infoOutput(), "Time: %.3f ms\n\n", float64(elapsed.Microseconds())/1000)
+	}
+}
+
+// renderRows prints the rows with the ResultWriter of the current output format
+func renderRows(header []string, rows [][]interface{}) {
+	writer := GetResultWriter(outputFormat)
+	writer.SetHeader(header)
+	for _, row := range rows {
+		writer.AppendRow(row)
+	}
+	writer.Render()
+	fmt.Fprintln(resultOutput)
+}
+
+// printSectionTitle prints the title of a result when a command prints multiple results (table format only)
+func printSectionTitle(title string) {
+	if isHumanReadableFormat(outputFormat) {
+		fmt.Fprintln(resultOutput, title)
+	}
+}
+
 // GetResultWriter returns the appropriate ResultWriter based on format
 func GetResultWriter(format string) ResultWriter {
-	if format == string(CSVFormat) {
-		return NewCSVWriter()
+	switch OutputFormat(format) {
+	case CSVFormat:
+		return resultSetWriter{NewCSVWriter()}
+	case JSONFormat:
+		return resultSetWriter{NewJSONWriter()}
+	case JSONLFormat:
+		return resultSetWriter{NewJSONLWriter()}
+	case VerticalFormat:
+		return resultSetWriter{NewVerticalWriter()}
+	case AutoFormat:
+		return resultSetWriter{NewAutoWriter()}
+	case BrowseFormat:
+		// the browser doesn't write to resultOutput (except its fallback, which uses GetResultWriter)
+		return NewBrowserWriter()
 	}
-	return NewTableWriter()
+	return resultSetWriter{NewTableWriter()}
+}
+
+// resultSetWriter sets resultWritten when the result set is started (the header is set only if there is a result)
+type resultSetWriter struct {
+	ResultWriter
+}
+
+func (r resultSetWriter) SetHeader(columns []string) {
+	resultWritten = true
+	r.ResultWriter.SetHeader(columns)
 }
 
+// CatalogKind is a type of schema objects listed by the \d* meta-commands
+type CatalogKind string
+
+const (
+	CatalogTables        CatalogKind = "tables"
+	CatalogIndexes       CatalogKind = "indexes"
+	CatalogViews         CatalogKind = "views"
+	CatalogSequences     CatalogKind = "sequences"
+	CatalogChangeStreams CatalogKind = "change streams"
+	CatalogModels        CatalogKind = "models"
+	CatalogRoles         CatalogKind = "roles"
+	CatalogGrants        CatalogKind = "grants"
+	CatalogSchemas       CatalogKind = "schemas"
+)
+
+// TransactionState is the state of the interactive transaction of a DatabaseClient
+type TransactionState int
+
+const (
+	// TxNone means that every statement is executed in its own transaction
+	TxNone TransactionState = iota
+	// TxReadWrite means that a read-write transaction is open
+	TxReadWrite
+	// TxReadOnly means that a read-only transaction is open
+	TxReadOnly
+)
+
 // DatabaseClient defines the interface for database operations
 type DatabaseClient interface {
 	// Execute runs a query and returns the results
@@ -112,12 +722,39 @@ type DatabaseClient interface {
 
 	ExecuteInTx(ctx context.Context, queries []string) error
 
+	// ExecuteDDL executes the schema changing statements, as one batch if the database supports it
+	ExecuteDDL(ctx context.Context, statements []string) error
+
 	// Close releases any resources
 	Close()
 
 	// GetName returns a descriptive name for the connection
 	GetName() string
 
-	// ListTables lists all tables in the database
-	ListTables(ctx context.Context) error
+	// ListCatalog lists the schema objects of the given kind, filtered by a LIKE pattern (empty pattern matches everything)
+	ListCatalog(ctx context.Context, kind CatalogKind, pattern string) error
+
+	// DescribeTable prints the definition of a table
+	DescribeTable(ctx context.Context, table string) error
+
+	// TableNames returns the names of all the tables, used for completion
+	TableNames(ctx context.Context) ([]string, error)
+
+	// ColumnNames returns the column names of a table, used for completion
+	ColumnNames(ctx context.Context, table string) ([]string, error)
+
+	// Dialect returns the SQL dialect of the database, used to split scripts to statements
+	Dialect(ctx context.Context) Dialect
+
+	// Begin starts a transaction which is used by Execute until Commit or Rollback is called
+	Begin(ctx context.Context, readOnly bool) error
+
+	// Commit commits the transaction started by Begin
+	Commit(ctx context.Context) error
+
+	// Rollback discards the transaction started by Begin
+	Rollback(ctx context.Context) error
+
+	// TransactionState returns the state of the transaction started by Begin
+	TransactionState() TransactionState
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureOutput returns the results rendered by f
+func captureOutput(f func()) string {
+	var buffer bytes.Buffer
+	resultOutput = &buffer
+	defer func() { resultOutput = os.Stdout }()
+	f()
+	return buffer.String()
+}
+
+func TestVerticalWriter(t *testing.T) {
+	out := captureOutput(func() {
+		writer := NewVerticalWriter()
+		writer.SetHeader([]string{"id", "description", ""})
+		writer.AppendRow([]interface{}{int64(1), "short", nil})
+		writer.AppendRow([]interface{}{int64(2), "two\nlines", Null})
+		writer.Render()
+	})
+	require.Equal(t, ""+
+		"-[ RECORD 1 ]------\n"+
+		"id          | 1\n"+
+		"description | short\n"+
+		"_2          | NULL\n"+
+		"-[ RECORD 2 ]------\n"+
+		"id          | 2\n"+
+		"description | two\n"+
+		"            | lines\n"+
+		"_2          | NULL\n", out)
+}
+
+func TestRecordSeparator(t *testing.T) {
+	require.Equal(t, "-[ RECORD 1 ]----+---", recordSeparator(1, 16, 2))
+	require.Equal(t, "-[ RECORD 12 ]-", recordSeparator(12, 2, 10))
+}
+
+func TestTableWriterBatches(t *testing.T) {
+	render := func(batchSize int, rows ...[]interface{}) string {
+		tableBatchSize = batchSize
+		defer func() { tableBatchSize = 0 }()
+		return captureOutput(func() {
+			writer := NewTableWriter()
+			writer.SetHeader([]string{"id", "name"})
+			for _, row := range rows {
+				writer.AppendRow(row)
+			}
+			writer.Render()
+		})
+	}
+	rows := [][]interface{}{{int64(1), "a"}, {int64(2), nil}, {int64(3), "ccc"}}
+
+	// a result which fits in one batch is rendered as before
+	require.Equal(t, render(0, rows...), render(10, rows...))
+
+	require.Equal(t, ""+
+		"+----+------+\n"+
+		"| ID | NAME |\n"+
+		"+----+------+\n"+
+		"|  1 | a    |\n"+
+		"|  2 | NULL |\n"+
+		"|  3 | ccc  |\n"+
+		"+----+------+\n", render(2, rows...))
+
+	// the column widths of the first batch are kept, longer values are wrapped
+	require.Equal(t, ""+
+		"+----+------+\n"+
+		"| ID | NAME |\n"+
+		"+----+------+\n"+
+		"|  1 | a    |\n"+
+		"|  2 | long |\n"+
+		"|    | er   |\n"+
+		"+----+------+\n", render(1, rows[0], []interface{}{int64(2), "longer"}))
+
+	// an empty column keeps its width in the following batches
+	require.Equal(t, ""+
+		"+----+---+\n"+
+		"| ID |   |\n"+
+		"+----+---+\n"+
+		"|  1 |   |\n"+
+		"|  2 | x |\n"+
+		"|  3 | y |\n"+
+		"|    | z |\n"+
+		"+----+---+\n", captureOutput(func() {
+		tableBatchSize = 1
+		defer func() { tableBatchSize = 0 }()
+		writer := NewTableWriter()
+		writer.SetHeader([]string{"id", ""})
+		writer.AppendRow([]interface{}{int64(1), ""})
+		writer.AppendRow([]interface{}{int64(2), "x"})
+		writer.AppendRow([]interface{}{int64(3), "yz"})
+		writer.Render()
+	}))
+}
+
+func TestJSONWriter(t *testing.T) {
+	render := func(rows ...[]interface{}) string {
+		return captureOutput(func() {
+			writer := NewJSONWriter()
+			writer.SetHeader([]string{"id", "name"})
+			for _, row := range rows {
+				writer.AppendRow(row)
+			}
+			writer.Render()
+		})
+	}
+	require.Equal(t, "[]\n", render())
+	require.Equal(t, "[\n  {\"id\":1,\"name\":\"a\"},\n  {\"id\":2,\"name\":null}\n]\n",
+		render([]interface{}{int64(1), "a"}, []interface{}{int64(2), Null}))
+}
+
+func TestJSONLWriter(t *testing.T) {
+	out := captureOutput(func() {
+		writer := NewJSONLWriter()
+		writer.SetHeader([]string{"id", ""})
+		writer.AppendRow([]interface{}{int64(1), "a"})
+		writer.AppendRow([]interface{}{int64(2), nil})
+		writer.Render()
+	})
+	require.Equal(t, "{\"id\":1,\"_1\":\"a\"}\n{\"id\":2,\"_1\":null}\n", out)
+}
+
+func TestStatementSummary(t *testing.T) {
+	require.Equal(t, "1 row in set (2ms)", StatementSummary{Rows: 1, Elapsed: 2 * time.Millisecond}.String())
+	require.Equal(t, "0 rows affected (0s)", StatementSummary{DML: true}.String())
+	require.Equal(t, "1 DDL statement executed (0s)", StatementSummary{DDL: true, Rows: 1}.String())
+	require.Equal(t, "3 DDL statements executed (0s)", StatementSummary{DDL: true, Rows: 3}.String())
+}
+
+func TestMarshalRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  []string
+		row      []interface{}
+		expected string
+	}{
+		{"column order", []string{"z", "a"}, []interface{}{int64(1), int64(2)}, `{"z":1,"a":2}`},
+		{"unnamed columns", nil, []interface{}{true, "x"}, `{"_0":true,"_1":"x"}`},
+		{"duplicated columns", []string{"id", "id", "id_1", ""}, []interface{}{int64(1), int64(2), int64(3), int64(4)}, `{"id":1,"id_1":2,"id_1_1":3,"_3":4}`},
+		{"duplicated struct fields", []string{"s"}, []interface{}{StructValue{{Name: "a", Value: int64(1)}, {Name: "a", Value: int64(2)}}}, `{"s":{"a":1,"a_1":2}}`},
+		{"null", []string{"n"}, []interface{}{Null}, `{"n":null}`},
+		{"nan and infinity", []string{"a", "b"}, []interface{}{math.NaN(), math.Inf(-1)}, `{"a":"NaN","b":"-Inf"}`},
+		{"array", []string{"a"}, []interface{}{[]interface{}{1.5, math.Inf(1), Null}}, `{"a":[1.5,"+Inf",null]}`},
+		{"struct", []string{"s"}, []interface{}{StructValue{{Name: "b", Value: int64(1)}, {Name: "a", Value: "x"}}}, `{"s":{"b":1,"a":"x"}}`},
+		{"json document", []string{"j"}, []interface{}{JSONValue(`{"k":[1,2]}`)}, `{"j":{"k":[1,2]}}`},
+		{"invalid json document", []string{"j"}, []interface{}{JSONValue(`{`)}, `{"j":"{"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, string(marshalRow(test.columns, test.row)))
+		})
+	}
+}
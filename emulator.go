@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultEmulatorHost is the address of the Spanner emulator, if SPANNER_EMULATOR_HOST is not set
+const defaultEmulatorHost = "localhost:9010"
+
+// useEmulator points all the Spanner clients to the emulator. The Spanner client libraries use the emulator
+// (without authentication) when SPANNER_EMULATOR_HOST is set.
+func useEmulator() error {
+	if os.Getenv("SPANNER_EMULATOR_HOST") != "" {
+		return nil
+	}
+	return errors.WithStack(os.Setenv("SPANNER_EMULATOR_HOST", defaultEmulatorHost))
+}
+
+// createEmulatorDatabase creates the instance and the database
+// (projects/{project}/instances/{instance}/databases/{database}) on the emulator, if they don't exist yet.
+// The admin clients are created with the same options as the Spanner client.
+func createEmulatorDatabase(ctx context.Context, databaseName string, clientOptions ...option.ClientOption) error {
+	parts := strings.Split(databaseName, "/")
+	if len(parts) != 6 {
+		return errors.Errorf("invalid database name %q", databaseName)
+	}
+	projectName := strings.Join(parts[:2], "/")
+	instanceName := strings.Join(parts[:4], "/")
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx, clientOptions...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create instance admin client")
+	}
+	defer instanceAdmin.Close()
+
+	_, err = instanceAdmin.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: instanceName})
+	if spanner.ErrCode(err) == codes.NotFound {
+		op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+			Parent:     projectName,
+			InstanceId: parts[3],
+			Instance: &instancepb.Instance{
+				Config:      projectName + "/instanceConfigs/emulator-config",
+				DisplayName: parts[3],
+				NodeCount:   1,
+			},
+		})
+		if err == nil {
+			_, err = op.Wait(ctx)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to create instance %s on the emulator", instanceName)
+		}
+		fmt.Fprintf(os.Stderr, "Created instance %s on the emulator\n", instanceName)
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	databaseAdmin, err := database.NewDatabaseAdminClient(ctx, clientOptions...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create database admin client")
+	}
+	defer databaseAdmin.Close()
+
+	_, err = databaseAdmin.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: databaseName})
+	if spanner.ErrCode(err) == codes.NotFound {
+		op, err := databaseAdmin.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+			Parent:          instanceName,
+			CreateStatement: "CREATE DATABASE `" + parts[5] + "`",
+		})
+		if err == nil {
+			_, err = op.Wait(ctx)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to create database %s on the emulator", databaseName)
+		}
+		fmt.Fprintf(os.Stderr, "Created database %s on the emulator\n", databaseName)
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
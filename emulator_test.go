@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseEmulator(t *testing.T) {
+	t.Setenv("SPANNER_EMULATOR_HOST", "")
+	require.NoError(t, useEmulator())
+	require.Equal(t, defaultEmulatorHost, os.Getenv("SPANNER_EMULATOR_HOST"))
+
+	t.Setenv("SPANNER_EMULATOR_HOST", "emulator:1234")
+	require.NoError(t, useEmulator())
+	require.Equal(t, "emulator:1234", os.Getenv("SPANNER_EMULATOR_HOST"))
+}
+
+func TestCreateEmulatorDatabaseInvalidName(t *testing.T) {
+	require.Error(t, createEmulatorDatabase(context.Background(), "p/i/db"))
+}
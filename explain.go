@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// parseExplain checks if the statement is EXPLAIN [ANALYZE] <query>, and returns the explained query
+func parseExplain(statement string) (query string, analyze bool, ok bool) {
+	var words []token
+	for _, t := range tokenize(statement, GoogleSQL) {
+		if t.kind == tokenWhitespace || t.kind == tokenComment {
+			continue
+		}
+		if len(words) == 0 && (t.kind != tokenWord || !strings.EqualFold(t.text, "EXPLAIN")) {
+			return "", false, false
+		}
+		if len(words) == 1 && t.kind == tokenWord && strings.EqualFold(t.text, "ANALYZE") {
+			analyze = true
+			words = append(words, t)
+			continue
+		}
+		if len(words) > 0 {
+			return statement[t.start:], analyze, true
+		}
+		words = append(words, t)
+	}
+	return "", false, false
+}
+
+// spannerAnalyzer is implemented by the Spanner transaction types which can return the query plan
+type spannerAnalyzer interface {
+	AnalyzeQuery(ctx context.Context, statement spanner.Statement) (*spannerpb.QueryPlan, error)
+	QueryWithStats(ctx context.Context, statement spanner.Statement) *spanner.RowIterator
+}
+
+// explain displays the query plan of the query. With analyze the query is executed (in PROFILE mode), and the
+// execution statistics of the operators are displayed too.
+func (s *SpannerClient) explain(ctx context.Context, query string, analyze bool) error {
+	start := time.Now()
+	var plan *spannerpb.QueryPlan
+	var err error
+	switch {
+	case s.transaction != nil:
+		plan, err = analyzeQuery(ctx, s.transaction, query, analyze)
+	case s.roTransaction != nil:
+		plan, err = analyzeQuery(ctx, s.roTransaction, query, analyze)
+	case isReadOnlyQuery([]string{query}, s.Dialect(ctx)):
+		plan, err = analyzeQuery(ctx, s.single(), query, analyze)
+	default:
+		_, err = s.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			var err error
+			plan, err = analyzeQuery(ctx, tx, query, analyze)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	header := []string{"ID", "Operator"}
+	if analyze {
+		header = append(header, "Rows", "Latency", "CPU Time")
+	}
+	writer := GetResultWriter(outputFormat)
+	writer.SetHeader(header)
+	rows := planRows(plan, analyze)
+	for _, row := range rows {
+		writer.AppendRow(row)
+	}
+	renderResult(writer, true, StatementSummary{Rows: int64(len(rows)), Elapsed: time.Since(start)})
+	return nil
+}
+
+// analyzeQuery returns the query plan. With profile the query is executed, and the plan contains the execution
+// statistics.
+func analyzeQuery(ctx context.Context, tx spannerAnalyzer, query string, profile bool) (*spannerpb.QueryPlan, error) {
+	stmt := spanner.Statement{
+		SQL:    query,
+		Params: queryParams(query),
+	}
+	if !profile {
+		plan, err := tx.AnalyzeQuery(ctx, stmt)
+		return plan, errors.WithStack(err)
+	}
+	iter := tx.QueryWithStats(ctx, stmt)
+	if err := iter.Do(func(r *spanner.Row) error { return nil }); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if iter.QueryPlan == nil {
+		return nil, errors.New("no query plan is returned")
+	}
+	return iter.QueryPlan, nil
+}
+
+// planRows renders the relational operators of the plan as an indented tree, one row per operator
+func planRows(plan *spannerpb.QueryPlan, analyze bool) [][]interface{} {
+	nodes := plan.GetPlanNodes()
+	var rows [][]interface{}
+	var visit func(index int32, linkType string, indent string, prefix string)
+	visit = func(index int32, linkType string, indent string, prefix string) {
+		if index < 0 || int(index) >= len(nodes) {
+			return
+		}
+		node := nodes[index]
+		operator := node.GetDisplayName()
+		if linkType != "" {
+			operator = "[" + linkType + "] " + operator
+		}
+		if details := planNodeDetails(node.GetMetadata()); details != "" {
+			operator += " (" + details + ")"
+		}
+		row := []interface{}{int64(node.GetIndex()), indent + prefix + operator}
+		if analyze {
+			stats := node.GetExecutionStats()
+			row = append(row, planStat(stats, "rows"), planStat(stats, "latency"), planStat(stats, "cpu_time"))
+		}
+		rows = append(rows, row)
+
+		var children []*spannerpb.PlanNode_ChildLink
+		for _, link := range node.GetChildLinks() {
+			child := link.GetChildIndex()
+			if int(child) < len(nodes) && nodes[child].GetKind() == spannerpb.PlanNode_RELATIONAL {
+				children = append(children, link)
+			}
+		}
+		if prefix == "+- " {
+			indent += "   "
+		} else if prefix == "|- " {
+			indent += "|  "
+		}
+		for i, link := range children {
+			childPrefix := "|- "
+			if i == len(children)-1 {
+				childPrefix = "+- "
+			}
+			visit(link.GetChildIndex(), link.GetType(), indent, childPrefix)
+		}
+	}
+	if len(nodes) > 0 {
+		visit(0, "", "", "")
+	}
+	return rows
+}
+
+// planNodeDetails formats the metadata of an operator (e.g. the scanned table) as key: value pairs
+func planNodeDetails(metadata *structpb.Struct) string {
+	var keys []string
+	for key, value := range metadata.GetFields() {
+		if _, ok := value.GetKind().(*structpb.Value_StructValue); ok || key == "subquery_cluster_node" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var details []string
+	for _, key := range keys {
+		value := metadata.GetFields()[key]
+		text := value.GetStringValue()
+		if _, ok := value.GetKind().(*structpb.Value_StringValue); !ok {
+			text = strings.Trim(fmt.Sprint(value.AsInterface()), "[]")
+		}
+		details = append(details, key+": "+text)
+	}
+	return strings.Join(details, ", ")
+}
+
+// planStat returns one statistic of the operator (e.g. "3" rows or "1.2 msecs"), or Null if it's missing
+func planStat(stats *structpb.Struct, name string) interface{} {
+	stat := stats.GetFields()[name].GetStructValue()
+	if stat == nil {
+		return Null
+	}
+	total := stat.GetFields()["total"].GetStringValue()
+	if unit := stat.GetFields()["unit"].GetStringValue(); unit != "" && unit != "rows" {
+		return total + " " + unit
+	}
+	return total
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+func TestParseExplain(t *testing.T) {
+	checkExplain := func(statement string, query string, analyze bool, ok bool) {
+		q, a, o := parseExplain(statement)
+		require.Equal(t, ok, o, statement)
+		require.Equal(t, query, q, statement)
+		require.Equal(t, analyze, a, statement)
+	}
+	checkExplain("EXPLAIN SELECT 1", "SELECT 1", false, true)
+	checkExplain("explain analyze\nSELECT 1", "SELECT 1", true, true)
+	checkExplain("-- plan\nEXPLAIN /* x */ SELECT * FROM t", "SELECT * FROM t", false, true)
+	checkExplain("SELECT 'EXPLAIN'", "", false, false)
+	checkExplain("EXPLAIN", "", false, false)
+	checkExplain("ANALYZE", "", false, false)
+}
+
+func TestPlanRows(t *testing.T) {
+	stats := func(rows string, latency string) *structpb.Struct {
+		s, err := structpb.NewStruct(map[string]interface{}{
+			"rows":    map[string]interface{}{"total": rows, "unit": "rows"},
+			"latency": map[string]interface{}{"total": latency, "unit": "msecs"},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	metadata, err := structpb.NewStruct(map[string]interface{}{"scan_target": "Singers", "scan_type": "TableScan"})
+	require.NoError(t, err)
+
+	plan := &spannerpb.QueryPlan{PlanNodes: []*spannerpb.PlanNode{
+		{Index: 0, Kind: spannerpb.PlanNode_RELATIONAL, DisplayName: "Distributed Union", ExecutionStats: stats("2", "1.5"),
+			ChildLinks: []*spannerpb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 4, Type: "Split Range"}}},
+		{Index: 1, Kind: spannerpb.PlanNode_RELATIONAL, DisplayName: "Serialize Result",
+			ChildLinks: []*spannerpb.PlanNode_ChildLink{{ChildIndex: 2, Type: "Input"}, {ChildIndex: 3}}},
+		{Index: 2, Kind: spannerpb.PlanNode_RELATIONAL, DisplayName: "Scan", Metadata: metadata},
+		{Index: 3, Kind: spannerpb.PlanNode_SCALAR, DisplayName: "Reference"},
+		{Index: 4, Kind: spannerpb.PlanNode_RELATIONAL, DisplayName: "Filter"},
+	}}
+
+	require.Equal(t, [][]interface{}{
+		{int64(0), "Distributed Union"},
+		{int64(1), "|- Serialize Result"},
+		{int64(2), "|  +- [Input] Scan (scan_target: Singers, scan_type: TableScan)"},
+		{int64(4), "+- [Split Range] Filter"},
+	}, planRows(plan, false))
+
+	rows := planRows(plan, true)
+	require.Equal(t, []interface{}{int64(0), "Distributed Union", "2", "1.5 msecs", Null}, rows[0])
+	require.Equal(t, []interface{}{int64(4), "+- [Split Range] Filter", Null, Null, Null}, rows[3])
+}
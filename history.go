@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxHistorySize is the number of entries kept in the history file
+const maxHistorySize = 1000
+
+// History is the list of the executed inputs, persisted to a file per connection
+type History struct {
+	entries []string
+	path    string
+}
+
+// historyDir returns the directory of the history files: $XDG_STATE_HOME/spanner-console/history
+// or ~/.config/spanner-console/history
+func historyDir() (string, error) {
+	if state := os.Getenv("XDG_STATE_HOME"); state != "" {
+		return filepath.Join(state, "spanner-console", "history"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get home directory")
+	}
+	return filepath.Join(home, ".config", "spanner-console", "history"), nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LoadHistory reads the history of the connection (alias or connection string).
+// Missing or unreadable history files result in an empty history.
+func LoadHistory(name string) *History {
+	dir, err := historyDir()
+	if err != nil {
+		return &History{}
+	}
+	h := &History{
+		path: filepath.Join(dir, unsafeFileNameChars.ReplaceAllString(name, "_")),
+	}
+	_ = h.load()
+	return h
+}
+
+// load reads the history file, which has one JSON encoded string per line (entries may be multi-line)
+func (h *History) load() error {
+	file, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		h.entries = append(h.entries, entry)
+	}
+	h.truncate()
+	return scanner.Err()
+}
+
+// Entries returns the history entries, oldest first
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add appends a new entry to the history and saves it. Repeated entries are stored only once.
+func (h *History) Add(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return nil
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	h.truncate()
+	return h.save()
+}
+
+func (h *History) truncate() {
+	if len(h.entries) > maxHistorySize {
+		h.entries = h.entries[len(h.entries)-maxHistorySize:]
+	}
+}
+
+// save writes the whole history to a temporary file, and replaces the history file with it
+func (h *History) save() error {
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return errors.Wrap(err, "failed to create history directory")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create history file")
+	}
+	defer os.Remove(tmp.Name())
+
+	out := bufio.NewWriter(tmp)
+	for _, entry := range h.entries {
+		line, _ := json.Marshal(entry)
+		out.Write(line)
+		out.WriteString("\n")
+	}
+	if err := out.Flush(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write history file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write history file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), h.path), "failed to replace history file")
+}
+
+// searchHistory returns the index of the newest entry at or before from, which contains the query
+// (case-insensitive). Returns -1 if there is no such entry.
+func searchHistory(history []string, query string, from int) int {
+	query = strings.ToLower(query)
+	for i := min(from, len(history)-1); i >= 0; i-- {
+		if strings.Contains(strings.ToLower(history[i]), query) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	h := LoadHistory("project/instance/db")
+	require.Empty(t, h.Entries())
+
+	require.NoError(t, h.Add("select 1;"))
+	require.NoError(t, h.Add("select 1;"))
+	require.NoError(t, h.Add("  "))
+	require.NoError(t, h.Add("select *\nfrom t;"))
+	require.NoError(t, h.Add("select 1;"))
+	require.Equal(t, []string{"select 1;", "select *\nfrom t;", "select 1;"}, h.Entries())
+
+	reloaded := LoadHistory("project/instance/db")
+	require.Equal(t, h.Entries(), reloaded.Entries())
+
+	require.Empty(t, LoadHistory("other").Entries())
+}
+
+func TestHistoryLimit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	h := LoadHistory("db")
+	for i := 0; i < maxHistorySize+10; i++ {
+		require.NoError(t, h.Add(fmt.Sprintf("select %d;", i)))
+	}
+	entries := LoadHistory("db").Entries()
+	require.Len(t, entries, maxHistorySize)
+	require.Equal(t, "select 10;", entries[0])
+}
+
+func TestSearchHistory(t *testing.T) {
+	history := []string{"select * from users;", "select 1;", "SELECT * FROM Orders;"}
+	require.Equal(t, 2, searchHistory(history, "from", 2))
+	require.Equal(t, 0, searchHistory(history, "from", 1))
+	require.Equal(t, 2, searchHistory(history, "orders", 10))
+	require.Equal(t, -1, searchHistory(history, "delete", 2))
+	require.Equal(t, -1, searchHistory(history, "from", -1))
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/x/ansi"
+	"strings"
 	"unicode"
 )
 
@@ -37,10 +38,12 @@ var DefaultKeyMap = KeyMap{
 
 type Textinput struct {
 	Prompt string
-	Text   string
-	Width  int
-	pos    int
-	cursor cursor.Model
+	// ContinuationPrompt is displayed in front of the second and following lines
+	ContinuationPrompt string
+	Text               string
+	Width              int
+	pos                int
+	cursor             cursor.Model
 }
 
 func NewTextInput() *Textinput {
@@ -77,9 +80,11 @@ func (t *Textinput) Update(msg tea.Msg) (*Textinput, tea.Cmd) {
 				t.Text = tail + head
 			}
 		case key.Matches(msg, DefaultKeyMap.LineEnd):
-			t.pos = len(t.Text)
+			_, end := t.currentLine()
+			t.pos = end
 		case key.Matches(msg, DefaultKeyMap.LineStart):
-			t.pos = 0
+			start, _ := t.currentLine()
+			t.pos = start
 		case key.Matches(msg, DefaultKeyMap.CharacterBackward):
 			if t.pos > 0 {
 				t.pos--
@@ -95,7 +100,7 @@ func (t *Textinput) Update(msg tea.Msg) (*Textinput, tea.Cmd) {
 				if tail == "" {
 					break
 				}
-				if tail[len(tail)-1] == ' ' && t.pos != initialPos {
+				if isSpace(tail[len(tail)-1]) && t.pos != initialPos {
 					break
 				}
 				t.pos--
@@ -107,7 +112,7 @@ func (t *Textinput) Update(msg tea.Msg) (*Textinput, tea.Cmd) {
 				if t.pos == len(t.Text) {
 					break
 				}
-				if t.pos > 0 && tail[len(tail)-1] == ' ' && t.pos != initialPos {
+				if t.pos > 0 && isSpace(tail[len(tail)-1]) && t.pos != initialPos {
 					break
 				}
 				t.pos++
@@ -115,15 +120,15 @@ func (t *Textinput) Update(msg tea.Msg) (*Textinput, tea.Cmd) {
 		default:
 			var filtered []rune
 			for _, r := range msg.Runes {
-				if unicode.IsPrint(r) {
+				switch {
+				case r == '\r' || r == '\n':
+					// pasted multi-line text
+					filtered = append(filtered, '\n')
+				case unicode.IsPrint(r):
 					filtered = append(filtered, r)
 				}
 			}
-			head, c, tail := t.HeadAndTail()
-			if len(filtered) > 0 {
-				t.Text = head + string(filtered) + c + tail
-				t.pos += len(string(filtered))
-			}
+			t.Insert(string(filtered))
 			return t, nil
 		}
 	}
@@ -140,11 +145,72 @@ func (t *Textinput) View() string {
 	if crs == "" {
 		crs = " "
 	}
+	if crs == "\n" {
+		// cursor at the end of a line
+		crs = " "
+		tail = "\n" + tail
+	}
 	t.cursor.SetChar(crs)
 	//debug := fmt.Sprintf("t:%s pos:%d h:%s c:%s t:%s", t.Text, t.pos, head, crs, tail)
 	debug := ""
-	return ansi.Wrap(debug+t.Prompt+head+t.cursor.View()+tail, t.Width, "")
+	lines := strings.Split(head+t.cursor.View()+tail, "\n")
+	for i, line := range lines {
+		prompt := t.Prompt
+		if i > 0 {
+			prompt = t.ContinuationPrompt
+		}
+		lines[i] = ansi.Wrap(prompt+line, t.Width, "")
+	}
+	return debug + strings.Join(lines, "\n")
+}
+
+// Insert inserts the text at the cursor position
+func (t *Textinput) Insert(s string) {
+	if s == "" {
+		return
+	}
+	head, c, tail := t.HeadAndTail()
+	t.Text = head + s + c + tail
+	t.pos += len(s)
+}
+
+// ReplaceWord replaces the text between start and the cursor
+func (t *Textinput) ReplaceWord(start int, s string) {
+	t.Text = t.Text[:start] + s + t.Text[t.pos:]
+	t.pos = start + len(s)
+}
+
+// currentLine returns the start and end offset of the line with the cursor
+func (t *Textinput) currentLine() (start int, end int) {
+	start = strings.LastIndexByte(t.Text[:t.pos], '\n') + 1
+	end = strings.IndexByte(t.Text[t.pos:], '\n')
+	if end < 0 {
+		return start, len(t.Text)
+	}
+	return start, t.pos + end
+}
 
+// MoveLine moves the cursor to the previous (delta=-1) or next (delta=1) line, keeping the column if possible.
+// Returns false if there is no such line.
+func (t *Textinput) MoveLine(delta int) bool {
+	start, end := t.currentLine()
+	column := t.pos - start
+	var lineStart, lineEnd int
+	switch {
+	case delta < 0 && start > 0:
+		lineEnd = start - 1
+		lineStart = strings.LastIndexByte(t.Text[:lineEnd], '\n') + 1
+	case delta > 0 && end < len(t.Text):
+		lineStart = end + 1
+		lineEnd = len(t.Text)
+		if next := strings.IndexByte(t.Text[lineStart:], '\n'); next >= 0 {
+			lineEnd = lineStart + next
+		}
+	default:
+		return false
+	}
+	t.pos = min(lineStart+column, lineEnd)
+	return true
 }
 
 func (t *Textinput) Value() string {
@@ -22,3 +22,32 @@ func TestHeadAndTail(t *testing.T) {
 	checkSplit(t, "a", 0, "", "a", "")
 
 }
+
+func TestMoveLine(t *testing.T) {
+	ti := Textinput{Text: "select *\nfrom t\nwhere a = 1"}
+
+	ti.pos = len(ti.Text)
+	require.True(t, ti.MoveLine(-1))
+	require.Equal(t, len("select *\nfrom t"), ti.pos)
+	require.True(t, ti.MoveLine(-1))
+	require.Equal(t, len("select"), ti.pos)
+	require.False(t, ti.MoveLine(-1))
+
+	ti.pos = 2
+	require.True(t, ti.MoveLine(1))
+	require.Equal(t, len("select *\nfr"), ti.pos)
+	require.True(t, ti.MoveLine(1))
+	require.Equal(t, len("select *\nfrom t\nwh"), ti.pos)
+	require.False(t, ti.MoveLine(1))
+}
+
+func TestInputComplete(t *testing.T) {
+	require.True(t, inputComplete("select 1;", GoogleSQL))
+	require.True(t, inputComplete("select 1; -- comment", GoogleSQL))
+	require.True(t, inputComplete("\\dt", GoogleSQL))
+	require.True(t, inputComplete("exit", GoogleSQL))
+	require.False(t, inputComplete("select 1", GoogleSQL))
+	require.False(t, inputComplete("select ';", GoogleSQL))
+	require.False(t, inputComplete("select 1; select 2", GoogleSQL))
+	require.False(t, inputComplete("select 1; /* comment", GoogleSQL))
+}
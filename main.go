@@ -1,18 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/pkg/errors"
+	"google.golang.org/api/option"
 )
 
 func main() {
@@ -24,78 +23,126 @@ func main() {
 }
 
 type Cli struct {
-	Alias           string        `arg:"" optional:"" help:"Alias name from ~/.config/spanner-console/alias"`
-	SpannerInstance string        `name:"spanner" help:"Spanner instance, in the form of projects/{project}/instances/{instance}/databases/{database} or {project}/{instance}/{database}"`
-	BigQueryProject string        `name:"bigquery" help:"BigQuery project ID"`
-	Transaction     bool          `name:"transaction" short:"t" help:"Execute all queries in a single transaction"`
-	OutputFormat    string        `name:"format" short:"f" help:"Output format (table|csv)" default:"table" enum:"table,csv"`
-	Staleness       time.Duration `name:"staleness" help:"Staleness duration for Spanner stale reads (e.g. 10s, 1m)"`
-	ExactTimestamp  string        `name:"exact-timestamp" help:"Exact timestamp for Spanner stale reads (RFC3339 format, e.g. 2006-01-02T15:04:05Z)"`
+	Console ConsoleCmd `cmd:"" default:"withargs" help:"Start the SQL console (default command)"`
+	Config  ConfigCmd  `cmd:"" help:"Show the profiles of the config file"`
+}
+
+type ConsoleCmd struct {
+	Alias            string            `arg:"" optional:"" help:"Profile name from ~/.config/spanner-console/config.yaml (or alias from the legacy ~/.config/spanner-console/alias)"`
+	SpannerInstance  string            `name:"spanner" help:"Spanner instance, in the form of projects/{project}/instances/{instance}/databases/{database} or {project}/{instance}/{database}"`
+	BigQueryProject  string            `name:"bigquery" help:"BigQuery project ID"`
+	Transaction      bool              `name:"transaction" short:"t" help:"Execute all queries in a single transaction"`
+	OutputFormat     string            `name:"format" short:"f" help:"Output format (table|csv|json|jsonl|vertical|auto), default is table"`
+	Staleness        time.Duration     `name:"staleness" help:"Staleness duration for Spanner stale reads (e.g. 10s, 1m)"`
+	ExactTimestamp   string            `name:"exact-timestamp" help:"Exact timestamp for Spanner stale reads (RFC3339 format, e.g. 2006-01-02T15:04:05Z)"`
+	StatementTimeout time.Duration     `name:"statement-timeout" help:"Cancel the statements which run longer (e.g. 30s, 5m), 0 means no limit"`
+	NullDisplay      string            `name:"null-display" help:"String used to display NULL values in table output" default:"NULL"`
+	Timing           bool              `name:"timing" help:"Print the wall time of each statement"`
+	Stats            bool              `name:"stats" help:"Print the execution statistics of each statement (rows scanned, CPU time, bytes processed, ...)"`
+	DatabaseRole     string            `name:"database-role" help:"Spanner database role used for fine-grained access control"`
+	CredentialsFile  string            `name:"credentials-file" help:"Service account key file used instead of the application default credentials"`
+	Emulator         bool              `name:"emulator" help:"Connect to the Spanner emulator (SPANNER_EMULATOR_HOST, default is localhost:9010), and create the instance and the database if they don't exist"`
+	Endpoint         string            `name:"endpoint" help:"Override the Spanner API endpoint (e.g. regional or private endpoint, host:port)"`
+	ReadOnly         bool              `name:"read-only" help:"Refuse the statements which may modify the database"`
+	DryRun           bool              `name:"dry-run" help:"Only estimate the processed bytes and the cost of the BigQuery queries, without executing them"`
+	MaxBytesBilled   string            `name:"max-bytes-billed" help:"Fail the BigQuery queries which would bill more bytes (e.g. 100GiB)"`
+	ConfirmBytes     string            `name:"confirm-bytes" help:"Ask for confirmation before executing BigQuery queries which would process more bytes (e.g. 10GiB)"`
+	NoPager          bool              `name:"no-pager" help:"Never display the results with the pager ($PAGER, default is less -S)"`
+	BatchSize        int               `name:"batch-size" help:"Render the tables in batches of N rows with the column widths of the first batch, instead of collecting the whole result (0 renders the whole table at once)"`
+	Browse           bool              `name:"browse" help:"Display the query results of the interactive console with the full-screen result browser"`
+	Params           map[string]string `name:"param" mapsep:"none" help:"Variable bound to the @name query parameters (name=value, can be repeated)"`
 }
 
 // Store outputFormat as a global variable for all DB clients to access
 var outputFormat string
 
-// resolveAlias looks up an alias in ~/.config/spanner-console/alias
-// Returns (type, connection-string, error)
-func resolveAlias(alias string) (string, string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", "", errors.Wrap(err, "failed to get home directory")
-	}
-
-	aliasPath := filepath.Join(home, ".config", "spanner-console", "alias")
-	file, err := os.Open(aliasPath)
-	if err != nil {
-		return "", "", errors.Wrap(err, "failed to open alias file")
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) >= 3 && parts[0] == alias {
-			return parts[1], parts[2], nil
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", "", errors.Wrap(err, "failed to read alias file")
-	}
-
-	return "", "", errors.Errorf("alias %q not found", alias)
-}
-
-func (c *Cli) Run() error {
+func (c *ConsoleCmd) Run() error {
 	// Set up appropriate client based on which flag was provided
 	ctx := context.Background()
 	
-	// Set the global output format
-	outputFormat = c.OutputFormat
-
-	// Resolve alias if provided
+	// Apply the settings of the profile, if they are not set by the flags
+	var profile Profile
 	if c.Alias != "" {
 		if c.SpannerInstance != "" || c.BigQueryProject != "" {
 			return errors.New("Cannot specify both alias and --spanner/--bigquery flags")
 		}
-		dbType, connStr, err := resolveAlias(c.Alias)
+		config, err := LoadConfig()
 		if err != nil {
 			return err
 		}
-		switch dbType {
+		profile, err = config.Profile(c.Alias)
+		if err != nil {
+			return err
+		}
+		switch profile.Type {
 		case "spanner":
-			c.SpannerInstance = connStr
+			c.SpannerInstance = profile.Connection
 		case "bigquery":
-			c.BigQueryProject = connStr
+			c.BigQueryProject = profile.Connection
 		default:
-			return errors.Errorf("unknown database type %q for alias %q", dbType, c.Alias)
+			return errors.Errorf("unknown database type %q for alias %q", profile.Type, c.Alias)
+		}
+		if c.OutputFormat == "" {
+			c.OutputFormat = profile.Format
+		}
+		if c.Staleness == 0 && c.ExactTimestamp == "" {
+			c.Staleness = profile.Staleness
+		}
+		if c.DatabaseRole == "" {
+			c.DatabaseRole = profile.DatabaseRole
 		}
+		if c.CredentialsFile == "" {
+			c.CredentialsFile = profile.CredentialsFile
+		}
+		if c.Endpoint == "" {
+			c.Endpoint = profile.Endpoint
+		}
+		c.Emulator = c.Emulator || profile.Emulator
+		c.ReadOnly = c.ReadOnly || profile.ReadOnly
+	}
+
+	// Set the global output format
+	if c.OutputFormat == "" {
+		c.OutputFormat = string(TableFormat)
+	}
+	if !isOutputFormat(c.OutputFormat) {
+		return errors.Errorf("unknown output format %q", c.OutputFormat)
+	}
+	outputFormat = c.OutputFormat
+	nullDisplay = c.NullDisplay
+	showTiming = c.Timing
+	showStats = c.Stats
+	if c.NoPager {
+		pagerMode = PagerOff
+	}
+	browseResults = c.Browse
+	if c.BatchSize < 0 {
+		return errors.New("batch size can't be negative")
+	}
+	tableBatchSize = c.BatchSize
+	if c.StatementTimeout < 0 {
+		return errors.New("statement timeout can't be negative")
+	}
+	statementTimeout = c.StatementTimeout
+	for name, value := range c.Params {
+		if err := setVariable(name, value); err != nil {
+			return err
+		}
+	}
+	if err := setPromptColor(profile.PromptColor); err != nil {
+		return errors.Wrapf(err, "invalid prompt color of alias %q", c.Alias)
 	}
 
+	var clientOptions []option.ClientOption
+	if c.CredentialsFile != "" {
+		clientOptions = append(clientOptions, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	if c.Endpoint != "" && c.Emulator {
+		return errors.New("Cannot specify both --emulator and --endpoint")
+	}
+
+	readOnly = c.ReadOnly
+
 	var dbClient DatabaseClient
 	var err error
 
@@ -135,9 +182,35 @@ func (c *Cli) Run() error {
 			useExactTimestamp = true
 		}
 		
-		dbClient, err = NewSpannerClient(ctx, c.SpannerInstance, prompt, c.Staleness, exactTimestamp, useExactTimestamp)
+		// the endpoint is a gRPC host:port, it's used only by the Spanner clients
+		if c.Endpoint != "" {
+			clientOptions = append(clientOptions, option.WithEndpoint(c.Endpoint))
+		}
+		if c.Emulator {
+			if err := useEmulator(); err != nil {
+				return err
+			}
+			if err := createEmulatorDatabase(ctx, c.SpannerInstance, clientOptions...); err != nil {
+				return err
+			}
+		}
+		dbClient, err = NewSpannerClient(ctx, c.SpannerInstance, prompt, c.Staleness, exactTimestamp, useExactTimestamp, c.DatabaseRole, clientOptions...)
 	} else if c.BigQueryProject != "" {
-		dbClient, err = NewBigQueryClient(ctx, c.BigQueryProject)
+		if c.Emulator || c.Endpoint != "" {
+			return errors.New("--emulator and --endpoint are supported only by Spanner")
+		}
+		options := BigQueryOptions{DryRun: c.DryRun, Location: profile.Location, Dataset: profile.Dataset}
+		if c.MaxBytesBilled != "" {
+			if options.MaxBytesBilled, err = parseBytes(c.MaxBytesBilled); err != nil {
+				return errors.Wrap(err, "invalid --max-bytes-billed")
+			}
+		}
+		if c.ConfirmBytes != "" {
+			if options.ConfirmBytes, err = parseBytes(c.ConfirmBytes); err != nil {
+				return errors.Wrap(err, "invalid --confirm-bytes")
+			}
+		}
+		dbClient, err = NewBigQueryClient(ctx, c.BigQueryProject, options, clientOptions...)
 	} else {
 		return errors.New("Either --spanner or --bigquery must be specified")
 	}
@@ -150,40 +223,97 @@ func (c *Cli) Run() error {
 
 	stat, _ := os.Stdin.Stat()
 
-	var queries []string
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
 		content, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return errors.Wrap(err, "failed to read from stdin")
 		}
-		for _, line := range strings.Split(string(content), ";") {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
-			fmt.Println(line)
-			if c.Transaction {
-				queries = append(queries, line)
-			} else {
-				err := dbClient.Execute(ctx, string(line))
-				if err != nil {
-					return errors.WithStack(err)
-				}
+		return runScript(ctx, dbClient, string(content), c.Transaction)
+	}
+
+	infoToResult = true
+	historyName := c.Alias
+	if historyName == "" {
+		historyName = dbClient.GetName()
+	}
+	return Loop(dbClient.GetName(), func(query string) {
+		err := withBrowser(func() error {
+			return runStatement(ctx, dbClient, query)
+		})
+		if errors.Is(err, errQueryCancelled) {
+			fmt.Println(err)
+		} else if err != nil {
+			fmt.Printf("Failed to execute query: %v\n", err)
+		}
+	}, dbClient, LoadHistory(historyName))
+}
+// runScript executes the statements piped to stdin, in one transaction if transaction is set
+func runScript(ctx context.Context, dbClient DatabaseClient, script string, transaction bool) error {
+	var queries []string
+	// consecutive DDL statements are executed as one batch
+	var ddl []string
+	flushDDL := func() error {
+		if len(ddl) == 0 {
+			return nil
+		}
+		statements := ddl
+		ddl = nil
+		err := timed(func() error {
+			return interruptible(ctx, func(ctx context.Context) error { return dbClient.ExecuteDDL(ctx, statements) })
+		})
+		return errors.WithStack(err)
+	}
+	dialect := dbClient.Dialect(ctx)
+	statements := SplitStatements(script, dialect)
+	if transaction {
+		// the schema changes are executed by the admin API, they can't be part of the transaction
+		for _, statement := range statements {
+			if isDDL(statement, dialect) {
+				return errors.Errorf("DDL statements can't be executed in a transaction, run the script without --transaction: %s", statement)
 			}
 		}
-		if len(queries) > 0 {
-			err := dbClient.ExecuteInTx(ctx, queries)
+	}
+	for _, line := range statements {
+		echoStatement(line)
+		if err := checkReadOnly(line, dialect); err != nil {
+			return err
+		}
+		if transaction {
+			queries = append(queries, line)
+		} else if isDDL(line, dialect) {
+			ddl = append(ddl, line)
+		} else {
+			if err := flushDDL(); err != nil {
+				return err
+			}
+			err := runStatement(ctx, dbClient, line)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 		}
-		return nil
 	}
-
-	return Loop(dbClient.GetName(), func(query string) {
-		err := dbClient.Execute(ctx, query)
+	if err := flushDDL(); err != nil {
+		return err
+	}
+	if len(queries) > 0 {
+		err := withPager(ctx, func(ctx context.Context) error {
+			return timed(func() error {
+				return interruptible(ctx, func(ctx context.Context) error { return dbClient.ExecuteInTx(ctx, queries) })
+			})
+		})
 		if err != nil {
-			fmt.Printf("Failed to execute query: %v\n", err)
+			return errors.WithStack(err)
 		}
-	}, dbClient)
-}
\ No newline at end of file
+	}
+	return nil
+}
+
+// echoStatement prints the executed statement of a script before its result. For the machine-readable formats
+// it's written to stderr to keep stdout parseable.
+func echoStatement(statement string) {
+	if isHumanReadableFormat(outputFormat) {
+		fmt.Fprintln(resultOutput, statement)
+		return
+	}
+	fmt.Fprintln(os.Stderr, statement)
+}
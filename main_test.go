@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scriptRecorder executes the queries of a script with a one-row result, and records the DDL statements
+type scriptRecorder struct {
+	DatabaseClient
+	ddl []string
+}
+
+func (s *scriptRecorder) Dialect(ctx context.Context) Dialect {
+	return GoogleSQL
+}
+
+func (s *scriptRecorder) Execute(ctx context.Context, query string) error {
+	writer := GetResultWriter(outputFormat)
+	writer.SetHeader([]string{"query"})
+	writer.AppendRow([]interface{}{query})
+	renderResult(writer, true, StatementSummary{Rows: 1})
+	return nil
+}
+
+func (s *scriptRecorder) ExecuteDDL(ctx context.Context, statements []string) error {
+	s.ddl = append(s.ddl, statements...)
+	return nil
+}
+
+func TestRunScriptJSON(t *testing.T) {
+	defer func() { outputFormat = "" }()
+	outputFormat = string(JSONLFormat)
+	out := captureOutput(func() {
+		require.NoError(t, runScript(context.Background(), &scriptRecorder{}, "SELECT 1;\nSELECT 'a;b';", false))
+	})
+
+	// only the results are written to stdout
+	var rows []map[string]string
+	decoder := json.NewDecoder(strings.NewReader(out))
+	for {
+		var row map[string]string
+		err := decoder.Decode(&row)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, out)
+		rows = append(rows, row)
+	}
+	require.Equal(t, []map[string]string{{"query": "SELECT 1"}, {"query": "SELECT 'a;b'"}}, rows)
+}
+
+func TestRunScriptTable(t *testing.T) {
+	defer func() { outputFormat = "" }()
+	outputFormat = string(TableFormat)
+	db := &scriptRecorder{}
+	out := captureOutput(func() {
+		require.NoError(t, runScript(context.Background(), db, "CREATE TABLE a (id INT64) PRIMARY KEY (id);\nSELECT 1;", false))
+	})
+	require.Equal(t, []string{"CREATE TABLE a (id INT64) PRIMARY KEY (id)"}, db.ddl)
+	require.True(t, strings.HasPrefix(out, "CREATE TABLE a (id INT64) PRIMARY KEY (id)\nSELECT 1\n"), out)
+}
+
+func TestRunScriptTransactionDDL(t *testing.T) {
+	db := &scriptRecorder{}
+	err := runScript(context.Background(), db, "INSERT INTO a (id) VALUES (1);\nDROP TABLE a;", true)
+	require.EqualError(t, err, "DDL statements can't be executed in a transaction, run the script without --transaction: DROP TABLE a")
+	require.Empty(t, db.ddl)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/pkg/errors"
+)
+
+// PagerMode controls when the results are displayed with the pager
+type PagerMode string
+
+const (
+	// PagerAuto uses the pager if the output is taller or wider than the terminal
+	PagerAuto PagerMode = "auto"
+	// PagerOn always uses the pager (if stdout is a terminal)
+	PagerOn PagerMode = "on"
+	// PagerOff never uses the pager
+	PagerOff PagerMode = "off"
+)
+
+// pagerMode is set by \pager (or --no-pager)
+var pagerMode = PagerAuto
+
+// defaultPager is used if $PAGER is not set
+const defaultPager = "less -S"
+
+// resultWritten is set when a result set is written to resultOutput. The pager is used only for the results, not
+// for an output which is only the summary of a statement (e.g. DDL, or a query displayed by the result browser).
+var resultWritten bool
+
+// errPagerClosed cancels the statement when the user quits the pager before the end of the result
+var errPagerClosed = errors.New("pager is closed")
+
+// withPager sends the output of f to stdout, or to the pager if stdout is a terminal and the result doesn't fit
+// the window (or the pager is on). The output is buffered only until it fills the window, so a streamed result
+// (--batch-size, json, jsonl, csv) is displayed while the statement is still running. The context of f is
+// cancelled if the pager is closed before the end of the result, the rest of the result is not fetched.
+func withPager(ctx context.Context, f func(ctx context.Context) error) error {
+	if pagerMode == PagerOff || !term.IsTerminal(os.Stdout.Fd()) {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	output := &pagerOutput{closed: func() { cancel(errPagerClosed) }}
+	resultOutput = output
+	resultWritten = false
+	err := f(ctx)
+	resultOutput = os.Stdout
+	output.Close()
+	if errors.Is(context.Cause(ctx), errPagerClosed) {
+		// the user has seen enough of the result
+		return nil
+	}
+	return err
+}
+
+// pagerOutput buffers the output until the result exceeds the window (or the result is started, if the pager is
+// on). Then the buffer is written to the pager (or to stdout, if the pager can't be started), and the rest of
+// the output is passed through without buffering.
+type pagerOutput struct {
+	buffer bytes.Buffer
+	// out is the pager or stdout, nil while the output is buffered
+	out   io.Writer
+	pager *pagerProcess
+	// closed is called when the pager is closed before the end of the output
+	closed func()
+}
+
+func (p *pagerOutput) Write(data []byte) (int, error) {
+	if p.out != nil {
+		return p.write(data)
+	}
+	p.buffer.Write(data)
+	if resultWritten && (pagerMode == PagerOn || exceedsTerminal(p.buffer.String())) {
+		if err := p.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// start starts the pager, and writes the buffered output to it
+func (p *pagerOutput) start() error {
+	p.out = os.Stdout
+	if pager, err := startPager(); err == nil {
+		p.pager = pager
+		p.out = pager.stdin
+	}
+	_, err := p.write(p.buffer.Bytes())
+	p.buffer.Reset()
+	return err
+}
+
+// write passes the output to the pager (or stdout). The write fails if the user has quit the pager.
+func (p *pagerOutput) write(data []byte) (int, error) {
+	n, err := p.out.Write(data)
+	if err != nil && p.pager != nil && p.closed != nil {
+		p.closed()
+	}
+	return n, errors.WithStack(err)
+}
+
+// Close writes the buffered output to stdout, or waits until the pager is closed
+func (p *pagerOutput) Close() error {
+	switch {
+	case p.pager != nil:
+		return p.pager.wait()
+	case p.out == nil:
+		_, err := os.Stdout.Write(p.buffer.Bytes())
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// exceedsTerminal checks if the text is taller or wider than the terminal
+func exceedsTerminal(text string) bool {
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return false
+	}
+	// the prompt is displayed after the output
+	return displayLines(text, width) > height-1 || maxLineWidth(text) > width
+}
+
+// displayLines returns the number of terminal lines of the text, the lines wider than the terminal are wrapped
+func displayLines(text string, width int) int {
+	lines := 0
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		lines += max(1, (ansi.StringWidth(line)+width-1)/max(width, 1))
+	}
+	return lines
+}
+
+// pagerProcess is the running pager, which displays what is written to its stdin
+type pagerProcess struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// interrupts receives (and drops) the Ctrl+C of the pager
+	interrupts chan os.Signal
+}
+
+// startPager starts $PAGER (or less -S)
+func startPager() (*pagerProcess, error) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Ctrl+C is handled by the pager, it shouldn't terminate the console. The signal is received and dropped
+	// instead of ignored, as an ignored signal would be ignored by the pager too (SIG_IGN is inherited by exec).
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+		}
+	}()
+	if err := cmd.Start(); err != nil {
+		stopInterrupts(interrupts)
+		return nil, errors.Wrapf(err, "failed to start pager %q", pager)
+	}
+	return &pagerProcess{name: pager, cmd: cmd, stdin: stdin, interrupts: interrupts}, nil
+}
+
+// wait closes the input of the pager, and waits until the user quits it
+func (p *pagerProcess) wait() error {
+	defer stopInterrupts(p.interrupts)
+	p.stdin.Close()
+	return errors.Wrapf(p.cmd.Wait(), "failed to run pager %q", p.name)
+}
+
+// stopInterrupts stops receiving Ctrl+C on the channel, and stops the goroutine which drains it
+func stopInterrupts(interrupts chan os.Signal) {
+	signal.Stop(interrupts)
+	close(interrupts)
+}
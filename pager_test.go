@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerOutput(t *testing.T) {
+	defer func() {
+		pagerMode = PagerAuto
+		resultWritten = false
+	}()
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("PAGER", "cat > "+out)
+	pagerMode = PagerOn
+
+	// the output is buffered until the result is started
+	resultWritten = false
+	output := &pagerOutput{}
+	fmt.Fprintln(output, "line 1")
+	require.Nil(t, output.pager)
+
+	// the pager is started with the first part of the result, it doesn't wait for the end of the statement
+	resultWritten = true
+	fmt.Fprintln(output, "line 2")
+	require.NotNil(t, output.pager)
+	require.Zero(t, output.buffer.Len())
+	fmt.Fprintln(output, "line 3")
+	require.NoError(t, output.Close())
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "line 1\nline 2\nline 3\n", string(content))
+
+	t.Setenv("PAGER", "exit 1")
+	output = &pagerOutput{}
+	fmt.Fprintln(output, "x")
+	require.Error(t, output.Close())
+}
+
+func TestPagerClosed(t *testing.T) {
+	defer func() {
+		pagerMode = PagerAuto
+		resultWritten = false
+	}()
+	t.Setenv("PAGER", "true")
+	pagerMode = PagerOn
+	resultWritten = true
+
+	// the writes fail after the user quits the pager, and the statement is cancelled
+	closed := false
+	output := &pagerOutput{closed: func() { closed = true }}
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		_, err = fmt.Fprintln(output, "row")
+		time.Sleep(time.Millisecond)
+	}
+	require.Error(t, err)
+	require.True(t, closed)
+	require.NoError(t, output.Close())
+}
+
+func TestWithPagerWithoutTerminal(t *testing.T) {
+	// stdout of the tests is not a terminal, the results are written directly
+	called := false
+	require.NoError(t, withPager(context.Background(), func(ctx context.Context) error {
+		called = true
+		require.Equal(t, os.Stdout, resultOutput)
+		return nil
+	}))
+	require.True(t, called)
+}
+
+func TestDisplayLines(t *testing.T) {
+	require.Equal(t, 1, displayLines("", 10))
+	require.Equal(t, 2, displayLines("a\nb\n", 10))
+	require.Equal(t, 3, displayLines("\n\n\n", 10))
+	require.Equal(t, 1, displayLines("0123456789", 10))
+	require.Equal(t, 3, displayLines("0123456789a\nb", 10))
+	require.Equal(t, 2, displayLines("\x1b[31m0123456789\x1b[0m\nárvíztűrő", 10))
+}
+
+func TestResultWritten(t *testing.T) {
+	defer func() { resultWritten = false }()
+
+	resultWritten = false
+	captureOutput(func() {
+		printSummary(StatementSummary{DDL: true, Rows: 1})
+		GetResultWriter(string(BrowseFormat)).SetHeader([]string{"a"})
+	})
+	require.False(t, resultWritten)
+
+	for _, format := range []OutputFormat{TableFormat, CSVFormat, JSONFormat, JSONLFormat, VerticalFormat, AutoFormat} {
+		resultWritten = false
+		GetResultWriter(string(format)).SetHeader([]string{"a"})
+		require.True(t, resultWritten, format)
+	}
+}
+
+func TestPagerSignals(t *testing.T) {
+	if _, err := os.Stat("/proc/self/status"); err != nil {
+		t.Skip("/proc is not available")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("PAGER", "grep SigIgn /proc/self/status > "+out)
+	pager, err := startPager()
+	require.NoError(t, err)
+	require.NoError(t, pager.wait())
+
+	// SIGINT (2) is not ignored by the pager
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	mask, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(string(content), "SigIgn:")), 16, 64)
+	require.NoError(t, err)
+	require.Zero(t, mask&(1<<(syscall.SIGINT-1)), string(content))
+
+	// Ctrl+C doesn't terminate the console while the pager is running
+	t.Setenv("PAGER", "sleep 0.2")
+	pager, err = startPager()
+	require.NoError(t, err)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+	require.NoError(t, pager.wait())
+}
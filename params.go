@@ -0,0 +1,141 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// variables are the session variables (set by \set or --param), bound as @name query parameters
+var variables = map[string]string{}
+
+// setVariable validates the name and stores the value of a session variable
+func setVariable(name string, value string) error {
+	if name == "" {
+		return errors.New("variable name is missing")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isWordChar(name[i], GoogleSQL) || i == 0 && isDigit(name[i]) {
+			return errors.Errorf("invalid variable name %q", name)
+		}
+	}
+	variables[name] = value
+	return nil
+}
+
+// lookupVariable finds a variable by name, case-insensitively (as query parameter names are case-insensitive)
+func lookupVariable(name string) (string, bool) {
+	if value, found := variables[name]; found {
+		return value, true
+	}
+	for n, value := range variables {
+		if strings.EqualFold(n, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseParamValue converts the text of a variable to a typed query parameter:
+// 'quoted' or "quoted" text is a STRING, true / false is a BOOL, integers are INT64, other numbers are FLOAT64,
+// and anything else is a STRING.
+func parseParamValue(value string) interface{} {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		quote := value[:1]
+		return strings.ReplaceAll(value[1:len(value)-1], quote+quote, quote)
+	}
+	if b, err := strconv.ParseBool(value); err == nil && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false")) {
+		return b
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if floatLiteral.MatchString(value) {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// floatLiteral matches the numeric literals (e.g. 1.5, .5, 1e10), ParseFloat alone would accept inf, nan and hex floats
+var floatLiteral = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// queryParams returns the session variables which are referenced by the query as @name parameters
+func queryParams(query string) map[string]interface{} {
+	var params map[string]interface{}
+	for _, t := range tokenize(query, GoogleSQL) {
+		if t.kind != tokenParam || !strings.HasPrefix(t.text, "@") || strings.HasPrefix(t.text, "@@") {
+			continue
+		}
+		name := t.text[1:]
+		if value, found := lookupVariable(name); found {
+			if params == nil {
+				params = map[string]interface{}{}
+			}
+			params[name] = parseParamValue(value)
+		}
+	}
+	return params
+}
+
+// paramTypeName returns the SQL type of the parameter value
+func paramTypeName(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "BOOL"
+	case int64:
+		return "INT64"
+	case float64:
+		return "FLOAT64"
+	}
+	return "STRING"
+}
+
+// sortedVariables returns the name of the variables in alphabetical order
+func sortedVariables() []string {
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitArgs splits the arguments of a meta-command at whitespace, keeping the quoted ('...', "...", `...`) arguments
+// together (with the quotes)
+func splitArgs(args string) []string {
+	var result []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range args {
+		switch {
+		case quote != 0:
+			current.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			current.WriteRune(r)
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				result = append(result, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		result = append(result, current.String())
+	}
+	return result
+}
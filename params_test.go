@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseParamValue(t *testing.T) {
+	require.Equal(t, int64(42), parseParamValue("42"))
+	require.Equal(t, int64(-1), parseParamValue("-1"))
+	require.Equal(t, 1.5, parseParamValue("1.5"))
+	require.Equal(t, 0.5, parseParamValue(".5"))
+	require.Equal(t, -2e10, parseParamValue("-2E10"))
+	require.Equal(t, 1.0, parseParamValue("1."))
+	for _, text := range []string{"inf", "-Inf", "infinity", "NaN", "0x1p-2", "1_000.5", "1e", "."} {
+		require.Equal(t, text, parseParamValue(text), text)
+	}
+	require.Equal(t, true, parseParamValue("TRUE"))
+	require.Equal(t, false, parseParamValue("false"))
+	require.Equal(t, "42", parseParamValue("'42'"))
+	require.Equal(t, "it's", parseParamValue("'it''s'"))
+	require.Equal(t, "hello world", parseParamValue(`"hello world"`))
+	require.Equal(t, "hello", parseParamValue("hello"))
+	require.Equal(t, "", parseParamValue(""))
+}
+
+func TestQueryParams(t *testing.T) {
+	variables = map[string]string{"id": "42", "Name": "'x'", "unused": "1"}
+	defer func() { variables = map[string]string{} }()
+
+	require.Equal(t, map[string]interface{}{"id": int64(42), "name": "x"},
+		queryParams("SELECT * FROM t WHERE id = @id AND name = @name AND other = @other"))
+	require.Nil(t, queryParams("SELECT '@id', @@version -- @id"))
+
+	require.NoError(t, setVariable("limit_1", "10"))
+	require.Error(t, setVariable("1st", "10"))
+	require.Error(t, setVariable("a-b", "10"))
+}
+
+func TestSplitArgs(t *testing.T) {
+	require.Equal(t, []string{"\\set", "name", "'hello  world'"}, splitArgs("\\set  name 'hello  world'"))
+	require.Equal(t, []string{"\\d", "`My Table`"}, splitArgs("\\d `My Table`"))
+	require.Nil(t, splitArgs("  "))
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/hex"
-	"strings"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
 
 	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
 	"cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/pkg/errors"
 
@@ -13,47 +19,249 @@ import (
 	"time"
 
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type SpannerClient struct {
 	client            *spanner.Client
+	adminClient       *database.DatabaseAdminClient
+	database          string
 	name              string
-	transaction       *spanner.ReadWriteTransaction
+	transaction       *spanner.ReadWriteStmtBasedTransaction
+	roTransaction     *spanner.ReadOnlyTransaction
 	staleness         time.Duration
 	exactTimestamp    time.Time
 	useExactTimestamp bool
+	// dialect is detected on first use
+	dialect *Dialect
+	// clientOptions are used by the database admin client too
+	clientOptions []option.ClientOption
+}
+
+// spannerQuerier is implemented by all the Spanner transaction types
+type spannerQuerier interface {
+	Query(ctx context.Context, statement spanner.Statement) *spanner.RowIterator
+	QueryWithStats(ctx context.Context, statement spanner.Statement) *spanner.RowIterator
 }
 
 func (s *SpannerClient) ExecuteInTx(ctx context.Context, queries []string) error {
-	return Execute(ctx, s.client, queries, s.staleness, s.exactTimestamp, s.useExactTimestamp)
+	if s.TransactionState() != TxNone {
+		return s.executeInOpenTransaction(ctx, queries)
+	}
+	return Execute(ctx, s.client, queries, s.Dialect(ctx), s.staleness, s.exactTimestamp, s.useExactTimestamp)
 }
 
 var _ DatabaseClient = (*SpannerClient)(nil)
 
-func NewSpannerClient(ctx context.Context, connectionString string, prompt string, staleness time.Duration, exactTimestamp time.Time, useExactTimestamp bool) (*SpannerClient, error) {
+func NewSpannerClient(ctx context.Context, connectionString string, prompt string, staleness time.Duration, exactTimestamp time.Time, useExactTimestamp bool, databaseRole string, clientOptions ...option.ClientOption) (*SpannerClient, error) {
 	client, err := spanner.NewClientWithConfig(ctx, connectionString, spanner.ClientConfig{
 		SessionPoolConfig:    spanner.DefaultSessionPoolConfig,
 		SessionLabels:        map[string]string{"application_name": "spanner-console"},
 		DisableRouteToLeader: false,
-	})
+		DatabaseRole:         databaseRole,
+	}, clientOptions...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &SpannerClient{
 		client:            client,
+		database:          connectionString,
 		name:              prompt,
 		staleness:         staleness,
 		exactTimestamp:    exactTimestamp,
 		useExactTimestamp: useExactTimestamp,
+		clientOptions:     clientOptions,
 	}, nil
 }
 
 func (s *SpannerClient) Execute(ctx context.Context, query string) error {
-	return Execute(ctx, s.client, []string{query}, s.staleness, s.exactTimestamp, s.useExactTimestamp)
+	if isDDL(query, s.Dialect(ctx)) {
+		return s.ExecuteDDL(ctx, []string{query})
+	}
+	if explained, analyze, ok := parseExplain(query); ok {
+		return s.explain(ctx, explained, analyze)
+	}
+	if s.TransactionState() != TxNone {
+		return s.executeInOpenTransaction(ctx, []string{query})
+	}
+	return Execute(ctx, s.client, []string{query}, s.Dialect(ctx), s.staleness, s.exactTimestamp, s.useExactTimestamp)
+}
+
+// executeInOpenTransaction runs the queries in the transaction started by Begin
+func (s *SpannerClient) executeInOpenTransaction(ctx context.Context, queries []string) error {
+	writer := GetResultWriter(outputFormat)
+	start := time.Now()
+
+	var tx spannerQuerier = s.roTransaction
+	if s.transaction != nil {
+		tx = s.transaction
+	}
+	summary, hasResult, err := queryInto(ctx, tx, queries, s.Dialect(ctx), writer)
+	if err != nil && s.transaction != nil && spanner.ErrCode(err) == codes.Aborted {
+		// an aborted transaction can't be used any more, the whole transaction should be retried
+		s.transaction.Rollback(ctx)
+		s.transaction = nil
+		return errors.Wrap(err, "transaction was aborted by Spanner and has been rolled back, please retry it with BEGIN")
+	}
+	if err != nil {
+		return err
+	}
+
+	summary.Elapsed = time.Since(start)
+	renderResult(writer, hasResult, summary)
+	return nil
+}
+
+// ExecuteDDL executes the schema changes as one long-running operation, and displays the progress until it's done
+func (s *SpannerClient) ExecuteDDL(ctx context.Context, statements []string) error {
+	if s.TransactionState() != TxNone {
+		return errors.New("DDL statements can't be executed in a transaction")
+	}
+	if s.adminClient == nil {
+		adminClient, err := database.NewDatabaseAdminClient(ctx, s.clientOptions...)
+		if err != nil {
+			return errors.Wrap(err, "failed to create database admin client")
+		}
+		s.adminClient = adminClient
+	}
+
+	start := time.Now()
+	op, err := s.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   s.database,
+		Statements: statements,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		err := op.Poll(ctx)
+		if metadata, metadataErr := op.Metadata(); metadataErr == nil && metadata != nil {
+			printDDLProgress(metadata)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr)
+			return errors.WithStack(err)
+		}
+		if op.Done() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return &runningOperationError{operation: op.Name()}
+		case <-time.After(time.Second):
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+
+	printSummary(StatementSummary{
+		DDL:     true,
+		Rows:    int64(len(statements)),
+		Elapsed: time.Since(start),
+	})
+	fmt.Fprintln(resultOutput)
+	return nil
+}
+
+// runningOperationError is returned when the wait for a schema change is cancelled (Ctrl+C or statement timeout).
+// The long-running operation is not cancelled, the schema change is still executed.
+type runningOperationError struct {
+	operation string
+}
+
+func (e *runningOperationError) Error() string {
+	return fmt.Sprintf("stopped waiting for the schema change, it keeps running in the background (operation %s)", e.operation)
+}
+
+// printDDLProgress shows the progress of the schema update operation on a single (overwritten) stderr line
+func printDDLProgress(metadata *databasepb.UpdateDatabaseDdlMetadata) {
+	total := len(metadata.GetStatements())
+	completed := len(metadata.GetCommitTimestamps())
+	var percent int32
+	if progress := metadata.GetProgress(); completed < len(progress) {
+		percent = progress[completed].GetProgressPercent()
+	}
+	if completed == total {
+		percent = 100
+	}
+	fmt.Fprintf(os.Stderr, "\rDDL: %d/%d statements completed, current statement %d%%", completed, total, percent)
+}
+
+func (s *SpannerClient) Begin(ctx context.Context, readOnly bool) error {
+	if s.TransactionState() != TxNone {
+		return errors.New("there is already a transaction in progress")
+	}
+	if readOnly {
+		s.roTransaction = s.client.ReadOnlyTransaction()
+		if s.useExactTimestamp {
+			s.roTransaction = s.roTransaction.WithTimestampBound(spanner.ReadTimestamp(s.exactTimestamp))
+		} else if s.staleness > 0 {
+			s.roTransaction = s.roTransaction.WithTimestampBound(spanner.ExactStaleness(s.staleness))
+		}
+		return nil
+	}
+	tx, err := spanner.NewReadWriteStmtBasedTransaction(ctx, s.client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.transaction = tx
+	return nil
+}
+
+func (s *SpannerClient) Commit(ctx context.Context) error {
+	switch {
+	case s.roTransaction != nil:
+		s.roTransaction.Close()
+		s.roTransaction = nil
+		return nil
+	case s.transaction != nil:
+		tx := s.transaction
+		s.transaction = nil
+		_, err := tx.Commit(ctx)
+		if spanner.ErrCode(err) == codes.Aborted {
+			return errors.Wrap(err, "transaction was aborted by Spanner, please retry it with BEGIN")
+		}
+		return errors.WithStack(err)
+	}
+	return errors.New("there is no transaction in progress")
+}
+
+func (s *SpannerClient) Rollback(ctx context.Context) error {
+	switch {
+	case s.roTransaction != nil:
+		s.roTransaction.Close()
+		s.roTransaction = nil
+		return nil
+	case s.transaction != nil:
+		s.transaction.Rollback(ctx)
+		s.transaction = nil
+		return nil
+	}
+	return errors.New("there is no transaction in progress")
+}
+
+func (s *SpannerClient) TransactionState() TransactionState {
+	switch {
+	case s.transaction != nil:
+		return TxReadWrite
+	case s.roTransaction != nil:
+		return TxReadOnly
+	}
+	return TxNone
 }
 
 func (s *SpannerClient) Close() {
+	if s.TransactionState() != TxNone {
+		fmt.Println("Rolling back the open transaction")
+		_ = s.Rollback(context.Background())
+	}
+	if s.adminClient != nil {
+		s.adminClient.Close()
+	}
 	s.client.Close()
 }
 
@@ -61,85 +269,336 @@ func (s *SpannerClient) GetName() string {
 	return s.name
 }
 
-// isReadOnlyQuery checks if all queries are read-only
-func isReadOnlyQuery(queries []string) bool {
-	for _, q := range queries {
-		q = strings.ToUpper(strings.TrimSpace(q))
-		q = strings.TrimSpace(removeComments(q))
-		if strings.HasPrefix(q, "INSERT") || strings.HasPrefix(q, "UPDATE") ||
-			strings.HasPrefix(q, "DELETE") || strings.HasPrefix(q, "CREATE") ||
-			strings.HasPrefix(q, "DROP") || strings.HasPrefix(q, "ALTER") {
-			return false
+// Dialect returns the dialect of the database (GoogleSQL or PostgreSQL). GoogleSQL is used if it can't be detected.
+func (s *SpannerClient) Dialect(ctx context.Context) Dialect {
+	if s.dialect != nil {
+		return *s.dialect
+	}
+	dialect := GoogleSQL
+	// this query is valid in both of the dialects
+	iter := s.client.Single().Query(ctx, spanner.Statement{
+		SQL: `SELECT option_value FROM information_schema.database_options WHERE option_name = 'database_dialect'`,
+	})
+	defer iter.Stop()
+	if row, err := iter.Next(); err == nil {
+		var value string
+		if err := row.Columns(&value); err == nil && value == "POSTGRESQL" {
+			dialect = PostgreSQL
 		}
 	}
-	return true
+	s.dialect = &dialect
+	return dialect
 }
 
-func removeComments(q string) string {
-	var result []string
-	for _, line := range strings.Split(q, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "--") {
-			continue
-		}
-		result = append(result, line)
-	}
-	return strings.Join(result, "\n")
+// isDML checks if the query modifies rows (INSERT, UPDATE, DELETE, ...)
+func isDML(query string, dialect Dialect) bool {
+	return classifyStatement(query, dialect).isDML()
 }
 
-func (s *SpannerClient) ListTables(ctx context.Context) error {
-	writer := GetResultWriter(outputFormat)
+// isDDL checks if the query is a schema change (CREATE, ALTER, DROP, ...)
+func isDDL(query string, dialect Dialect) bool {
+	return classifyStatement(query, dialect).isDDL()
+}
 
-	// Set up header
-	writer.SetHeader([]string{"Table Name"})
+// isReadOnlyQuery checks if all queries are read-only
+func isReadOnlyQuery(queries []string, dialect Dialect) bool {
+	for _, q := range queries {
+		if !classifyStatement(q, dialect).isReadOnly() {
+			return false
+		}
+	}
+	return true
+}
 
+// TableNames returns the names of all the tables in the database
+func (s *SpannerClient) TableNames(ctx context.Context) ([]string, error) {
 	// Query for all tables in the database
-	stmt := spanner.Statement{
+	return s.queryStrings(ctx, spanner.Statement{
 		SQL: `SELECT table_name 
 		      FROM information_schema.tables 
 		      WHERE table_catalog = '' AND table_schema = '' 
 		      ORDER BY table_name`,
+	})
+}
+
+// ColumnNames returns the names of the columns of the table, in the order of the definition
+func (s *SpannerClient) ColumnNames(ctx context.Context, table string) ([]string, error) {
+	return s.queryStrings(ctx, spanner.Statement{
+		SQL: `SELECT column_name
+		      FROM information_schema.columns
+		      WHERE table_catalog = '' AND table_schema = '' AND table_name = @table
+		      ORDER BY ordinal_position`,
+		Params: map[string]interface{}{"table": table},
+	})
+}
+
+// DescribeTable prints the columns, the primary key, the parent (for interleaved tables), the indexes
+// and the foreign keys of the table
+func (s *SpannerClient) DescribeTable(ctx context.Context, table string) error {
+	found, err := s.describeSection(ctx, "Table", `
+		SELECT t.table_name AS `+"`Table`"+`,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT CONCAT(c.column_name, IF(c.column_ordering = 'DESC', ' DESC', ''))
+		           FROM information_schema.index_columns c
+		           WHERE c.table_catalog = t.table_catalog AND c.table_schema = t.table_schema
+		             AND c.table_name = t.table_name AND c.index_name = 'PRIMARY_KEY'
+		           ORDER BY c.ordinal_position), ', ') AS `+"`Primary Key`"+`,
+		       t.parent_table_name AS Parent,
+		       t.on_delete_action AS `+"`On Delete`"+`
+		FROM information_schema.tables t
+		WHERE t.table_catalog = '' AND t.table_schema = '' AND t.table_name = @table`, table)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("table %q not found", table)
+	}
+
+	_, err = s.describeSection(ctx, "Columns", `
+		SELECT column_name AS `+"`Column`"+`,
+		       spanner_type AS Type,
+		       is_nullable AS Nullable,
+		       column_default AS `+"`Default`"+`,
+		       generation_expression AS Generated,
+		       is_stored AS Stored
+		FROM information_schema.columns
+		WHERE table_catalog = '' AND table_schema = '' AND table_name = @table
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.describeSection(ctx, "Indexes", `
+		SELECT i.index_name AS `+"`Index`"+`,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT CONCAT(c.column_name, IF(c.column_ordering = 'DESC', ' DESC', ''))
+		           FROM information_schema.index_columns c
+		           WHERE c.table_catalog = i.table_catalog AND c.table_schema = i.table_schema
+		             AND c.table_name = i.table_name AND c.index_name = i.index_name
+		             AND c.ordinal_position IS NOT NULL
+		           ORDER BY c.ordinal_position), ', ') AS Columns,
+		       i.is_unique AS `+"`Unique`"+`,
+		       i.is_null_filtered AS `+"`Null Filtered`"+`,
+		       NULLIF(i.parent_table_name, '') AS `+"`Interleaved In`"+`,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT c.column_name
+		           FROM information_schema.index_columns c
+		           WHERE c.table_catalog = i.table_catalog AND c.table_schema = i.table_schema
+		             AND c.table_name = i.table_name AND c.index_name = i.index_name
+		             AND c.ordinal_position IS NULL
+		           ORDER BY c.column_name), ', ') AS Storing
+		FROM information_schema.indexes i
+		WHERE i.table_catalog = '' AND i.table_schema = '' AND i.table_name = @table AND i.index_type = 'INDEX'
+		ORDER BY i.index_name`, table)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.describeSection(ctx, "Foreign Keys", `
+		SELECT tc.constraint_name AS `+"`Foreign Key`"+`,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT k.column_name
+		           FROM information_schema.key_column_usage k
+		           WHERE k.constraint_catalog = tc.constraint_catalog AND k.constraint_schema = tc.constraint_schema
+		             AND k.constraint_name = tc.constraint_name
+		           ORDER BY k.ordinal_position), ', ') AS Columns,
+		       (SELECT MAX(u.table_name)
+		        FROM information_schema.constraint_column_usage u
+		        WHERE u.constraint_catalog = tc.constraint_catalog AND u.constraint_schema = tc.constraint_schema
+		          AND u.constraint_name = tc.constraint_name) AS `+"`Referenced Table`"+`,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT r.column_name
+		           FROM information_schema.key_column_usage k
+		           JOIN information_schema.key_column_usage r
+		             ON r.constraint_catalog = rc.unique_constraint_catalog AND r.constraint_schema = rc.unique_constraint_schema
+		            AND r.constraint_name = rc.unique_constraint_name AND r.ordinal_position = k.position_in_unique_constraint
+		           WHERE k.constraint_catalog = tc.constraint_catalog AND k.constraint_schema = tc.constraint_schema
+		             AND k.constraint_name = tc.constraint_name
+		           ORDER BY k.ordinal_position), ', ') AS `+"`Referenced Columns`"+`,
+		       rc.delete_rule AS `+"`On Delete`"+`
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.referential_constraints rc
+		  ON rc.constraint_catalog = tc.constraint_catalog AND rc.constraint_schema = tc.constraint_schema
+		 AND rc.constraint_name = tc.constraint_name
+		WHERE tc.table_catalog = '' AND tc.table_schema = '' AND tc.table_name = @table
+		  AND tc.constraint_type = 'FOREIGN KEY'
+		ORDER BY tc.constraint_name`, table)
+	return err
+}
+
+// describeSection executes a catalog query with the table parameter, and prints the result with the title.
+// Nothing is printed if the query returns no rows.
+func (s *SpannerClient) describeSection(ctx context.Context, title string, sql string, table string) (bool, error) {
+	header, rows, err := s.queryCatalog(ctx, spanner.Statement{
+		SQL:    sql,
+		Params: map[string]interface{}{"table": table},
+	})
+	if err != nil || len(rows) == 0 {
+		return false, err
+	}
+
+	printSectionTitle(title)
+	renderRows(header, rows)
+	return true, nil
+}
+
+// queryCatalog executes a catalog query, and returns the column names and the converted rows
+func (s *SpannerClient) queryCatalog(ctx context.Context, stmt spanner.Statement) ([]string, [][]interface{}, error) {
+	iter := s.single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	var rows [][]interface{}
+	for {
+		row, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		rows = append(rows, convertToRow(row))
+	}
+
+	var header []string
+	for _, field := range iter.Metadata.GetRowType().GetFields() {
+		header = append(header, field.GetName())
 	}
+	return header, rows, nil
+}
 
-	// Use stale reads if staleness is set
+// spannerCatalogQueries are the information schema queries of the catalog listings, filtered by the @pattern LIKE pattern
+var spannerCatalogQueries = map[CatalogKind]string{
+	CatalogTables: `
+		SELECT table_name AS ` + "`Table Name`" + `
+		FROM information_schema.tables
+		WHERE table_catalog = '' AND table_schema = '' AND table_name LIKE @pattern
+		ORDER BY table_name`,
+	CatalogIndexes: `
+		SELECT table_name AS ` + "`Table`" + `,
+		       index_name AS ` + "`Index`" + `,
+		       index_type AS Type,
+		       is_unique AS ` + "`Unique`" + `,
+		       is_null_filtered AS ` + "`Null Filtered`" + `,
+		       index_state AS State
+		FROM information_schema.indexes
+		WHERE table_catalog = '' AND table_schema = '' AND index_type != 'PRIMARY_KEY' AND index_name LIKE @pattern
+		ORDER BY table_name, index_name`,
+	CatalogViews: `
+		SELECT table_name AS ` + "`View`" + `,
+		       view_definition AS Definition
+		FROM information_schema.views
+		WHERE table_catalog = '' AND table_schema = '' AND table_name LIKE @pattern
+		ORDER BY table_name`,
+	CatalogSequences: `
+		SELECT name AS Sequence,
+		       data_type AS Type
+		FROM information_schema.sequences
+		WHERE catalog = '' AND schema = '' AND name LIKE @pattern
+		ORDER BY name`,
+	CatalogChangeStreams: `
+		SELECT cs.change_stream_name AS ` + "`Change Stream`" + `,
+		       cs.all AS ` + "`All Tables`" + `,
+		       ARRAY_TO_STRING(ARRAY(
+		           SELECT t.table_name
+		           FROM information_schema.change_stream_tables t
+		           WHERE t.change_stream_catalog = cs.change_stream_catalog AND t.change_stream_schema = cs.change_stream_schema
+		             AND t.change_stream_name = cs.change_stream_name
+		           ORDER BY t.table_name), ', ') AS Tables
+		FROM information_schema.change_streams cs
+		WHERE cs.change_stream_catalog = '' AND cs.change_stream_schema = '' AND cs.change_stream_name LIKE @pattern
+		ORDER BY cs.change_stream_name`,
+	CatalogModels: `
+		SELECT model_name AS Model,
+		       is_remote AS Remote
+		FROM information_schema.models
+		WHERE model_catalog = '' AND model_schema = '' AND model_name LIKE @pattern
+		ORDER BY model_name`,
+	CatalogRoles: `
+		SELECT role_name AS Role,
+		       is_system AS System
+		FROM information_schema.roles
+		WHERE role_name LIKE @pattern
+		ORDER BY role_name`,
+	CatalogGrants: `
+		SELECT table_name AS ` + "`Table`" + `,
+		       CAST(NULL AS STRING) AS ` + "`Column`" + `,
+		       privilege_type AS Privilege,
+		       grantee AS Grantee
+		FROM information_schema.table_privileges
+		WHERE table_catalog = '' AND table_schema = '' AND table_name LIKE @pattern
+		UNION ALL
+		SELECT table_name, column_name, privilege_type, grantee
+		FROM information_schema.column_privileges
+		WHERE table_catalog = '' AND table_schema = '' AND table_name LIKE @pattern
+		ORDER BY 1, 4, 2, 3`,
+	CatalogSchemas: `
+		SELECT schema_name AS Schema
+		FROM information_schema.schemata
+		WHERE catalog_name = '' AND schema_name LIKE @pattern
+		ORDER BY schema_name`,
+}
+
+// ListCatalog lists the schema objects of the given kind, which match the LIKE pattern (all of them if it's empty)
+func (s *SpannerClient) ListCatalog(ctx context.Context, kind CatalogKind, pattern string) error {
+	sql, found := spannerCatalogQueries[kind]
+	if !found {
+		return errors.Errorf("listing %s is not supported by Spanner", kind)
+	}
+	if pattern == "" {
+		pattern = "%"
+	}
+	header, rows, err := s.queryCatalog(ctx, spanner.Statement{
+		SQL:    sql,
+		Params: map[string]interface{}{"pattern": pattern},
+	})
+	if err != nil {
+		return err
+	}
+	renderRows(header, rows)
+	return nil
+}
+
+// single returns a single-use read-only transaction for the catalog queries, which uses stale reads if staleness is set
+func (s *SpannerClient) single() *spanner.ReadOnlyTransaction {
 	singleUse := s.client.Single()
 	if s.useExactTimestamp {
 		singleUse = singleUse.WithTimestampBound(spanner.ReadTimestamp(s.exactTimestamp))
 	} else if s.staleness > 0 {
 		singleUse = singleUse.WithTimestampBound(spanner.ExactStaleness(s.staleness))
 	}
-	iter := singleUse.Query(ctx, stmt)
+	return singleUse
+}
+
+// queryStrings executes a query which returns a single STRING column
+func (s *SpannerClient) queryStrings(ctx context.Context, stmt spanner.Statement) ([]string, error) {
+	iter := s.single().Query(ctx, stmt)
 	defer iter.Stop()
 
+	var result []string
 	for {
 		row, err := iter.Next()
 		if errors.Is(err, iterator.Done) {
 			break
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		var tableName string
-		if err := row.Columns(&tableName); err != nil {
-			return err
+		var value string
+		if err := row.Columns(&value); err != nil {
+			return nil, err
 		}
-
-		writer.AppendRow([]interface{}{tableName})
+		result = append(result, value)
 	}
-
-	writer.Render()
-	fmt.Println()
-	return nil
+	return result, nil
 }
 
-func Execute(ctx context.Context, client *spanner.Client, queries []string, staleness time.Duration, exactTimestamp time.Time, useExactTimestamp bool) error {
+func Execute(ctx context.Context, client *spanner.Client, queries []string, dialect Dialect, staleness time.Duration, exactTimestamp time.Time, useExactTimestamp bool) error {
 	writer := GetResultWriter(outputFormat)
-
-	var headerPrinted bool
+	start := time.Now()
 
 	// If we have staleness set and only read queries, use stale reads
-	if isReadOnlyQuery(queries) {
+	if isReadOnlyQuery(queries, dialect) {
 		// Create a read-only transaction with the staleness bound
 		ro := client.ReadOnlyTransaction()
 		if useExactTimestamp {
@@ -149,147 +608,245 @@ func Execute(ctx context.Context, client *spanner.Client, queries []string, stal
 		}
 		defer ro.Close()
 
-		for _, query := range queries {
-			if query == "" {
-				continue
-			}
-			err := ro.Query(ctx, spanner.Statement{
-				SQL: query,
-			}).Do(func(r *spanner.Row) error {
-				if !headerPrinted {
-					var header []string
-					for _, name := range r.ColumnNames() {
-						header = append(header, name)
-					}
-					writer.SetHeader(header)
-					headerPrinted = true
-				}
-				writer.AppendRow(convertToRow(r))
-				return nil
-			})
-			if err != nil {
-				return errors.WithStack(err)
-			}
+		summary, hasResult, err := queryInto(ctx, ro, queries, dialect, writer)
+		if err != nil {
+			return err
 		}
 
-		writer.Render()
-		fmt.Println()
+		summary.Elapsed = time.Since(start)
+		renderResult(writer, hasResult, summary)
 		return nil
 	}
 
-	// For write transactions or no staleness, use read-write transaction
+	// For write transactions or no staleness, use read-write transaction. The function is retried if the
+	// transaction is aborted, so the result is written only after the commit.
+	var summary StatementSummary
+	var hasResult bool
+	var result *resultBuffer
 	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, transaction *spanner.ReadWriteTransaction) error {
-		for _, query := range queries {
-			if query == "" {
-				continue
-			}
-			err := transaction.Query(ctx, spanner.Statement{
-				SQL: query,
-			}).Do(func(r *spanner.Row) error {
-				if !headerPrinted {
-					var header []string
-					for _, name := range r.ColumnNames() {
-						header = append(header, name)
-					}
-					writer.SetHeader(header)
-					headerPrinted = true
-				}
-				writer.AppendRow(convertToRow(r))
-				return nil
-			})
-			if err != nil {
-				return errors.WithStack(err)
-			}
-		}
-		return nil
+		var err error
+		result = &resultBuffer{}
+		summary, hasResult, err = queryInto(ctx, transaction, queries, dialect, result)
+		return err
 	})
+	if err != nil {
+		return err
+	}
 
-	writer.Render()
-	fmt.Println()
-	return err
+	result.writeTo(writer)
+	summary.Elapsed = time.Since(start)
+	renderResult(writer, hasResult, summary)
+	return nil
 }
 
-func convertToRow(r *spanner.Row) []interface{} {
-	var row []interface{}
+// resultBuffer is a ResultWriter which collects the result set, to write it later to another ResultWriter
+type resultBuffer struct {
+	header []string
+	rows   [][]interface{}
+}
 
-	for ix := range r.Size() {
+func (r *resultBuffer) SetHeader(columns []string) {
+	r.header = columns
+}
 
-		switch r.ColumnType(ix).Code {
-		case spannerpb.TypeCode_BOOL:
-			var v bool
-			err := r.Column(ix, &v)
-			if err != nil {
-				row = append(row, err.Error())
-			}
-			row = append(row, v)
-		case spannerpb.TypeCode_STRING:
-			var v *string
-			err := r.Column(ix, &v)
-			if err != nil {
-				row = append(row, err.Error())
-			}
-			if v == nil {
-				row = append(row, nil)
-			} else {
-				row = append(row, *v)
-			}
+func (r *resultBuffer) AppendRow(row []interface{}) {
+	r.rows = append(r.rows, row)
+}
 
-		case spannerpb.TypeCode_INT64:
-			var v *int64
-			err := r.Column(ix, &v)
-			if err != nil {
-				row = append(row, err.Error())
-			}
-			if v == nil {
-				row = append(row, "nil")
-			} else {
-				row = append(row, *v)
-			}
+func (r *resultBuffer) Render() {
+}
 
-		case spannerpb.TypeCode_FLOAT64:
-			var v *float64
-			err := r.Column(ix, &v)
-			if err != nil {
-				row = append(row, err.Error())
-			}
-			if v == nil {
-				row = append(row, "nil")
-			} else {
-				row = append(row, *v)
-			}
-		case spannerpb.TypeCode_FLOAT32:
-			var v *float32
-			err := r.Column(ix, &v)
-			if err != nil {
-				row = append(row, err.Error())
+// writeTo writes the collected result set to the writer, without rendering it
+func (r *resultBuffer) writeTo(writer ResultWriter) {
+	if r.header == nil && len(r.rows) == 0 {
+		return
+	}
+	writer.SetHeader(r.header)
+	for _, row := range r.rows {
+		writer.AppendRow(row)
+	}
+}
+
+// queryInto executes the queries with the given transaction, and appends all the results to the writer.
+// hasResult is false if none of the queries returned a result set (DML without THEN RETURN).
+func queryInto(ctx context.Context, tx spannerQuerier, queries []string, dialect Dialect, writer ResultWriter) (summary StatementSummary, hasResult bool, err error) {
+	for _, query := range queries {
+		if query == "" {
+			continue
+		}
+		var rows int64
+		stmt := spanner.Statement{
+			SQL:    query,
+			Params: queryParams(query),
+		}
+		var iter *spanner.RowIterator
+		if showStats {
+			iter = tx.QueryWithStats(ctx, stmt)
+		} else {
+			iter = tx.Query(ctx, stmt)
+		}
+		err := iter.Do(func(r *spanner.Row) error {
+			if !hasResult {
+				writer.SetHeader(r.ColumnNames())
+				hasResult = true
 			}
-			if v == nil {
-				row = append(row, "nil")
-			} else {
-				row = append(row, *v)
+			writer.AppendRow(convertToRow(r))
+			rows++
+			return nil
+		})
+		if err != nil {
+			return summary, hasResult, errors.WithStack(err)
+		}
+		// queries without any row still have the column names in the metadata
+		if !hasResult && iter.Metadata != nil && len(iter.Metadata.GetRowType().GetFields()) > 0 {
+			var header []string
+			for _, field := range iter.Metadata.GetRowType().GetFields() {
+				header = append(header, field.GetName())
 			}
-		case spannerpb.TypeCode_BYTES:
-			var v []byte
-			err := r.Column(ix, &v)
+			writer.SetHeader(header)
+			hasResult = true
+		}
+		if isDML(query, dialect) {
+			summary.DML = true
+			summary.Rows += iter.RowCount
+		} else {
+			summary.Rows += rows
+		}
+		summary.Stats = append(summary.Stats, spannerStats(iter.QueryStats)...)
+	}
+	return summary, hasResult, nil
+}
+
+// spannerQueryStats are the displayed keys of the query statistics, and their labels
+var spannerQueryStats = []struct{ key, name string }{
+	{"rows_scanned", "rows scanned"},
+	{"cpu_time", "CPU time"},
+	{"elapsed_time", "elapsed time"},
+	{"optimizer_version", "optimizer version"},
+}
+
+// spannerStats picks the interesting values of the query statistics (only returned by QueryWithStats)
+func spannerStats(queryStats map[string]interface{}) []QueryStat {
+	var stats []QueryStat
+	for _, stat := range spannerQueryStats {
+		if value, found := queryStats[stat.key]; found {
+			stats = append(stats, QueryStat{Name: stat.name, Value: fmt.Sprint(value)})
+		}
+	}
+	return stats
+}
+
+func convertToRow(r *spanner.Row) []interface{} {
+	row := make([]interface{}, r.Size())
+	for ix := range r.Size() {
+		var v spanner.GenericColumnValue
+		if err := r.Column(ix, &v); err != nil {
+			row[ix] = err.Error()
+			continue
+		}
+		value, err := decodeSpannerValue(v.Type, v.Value)
+		if err != nil {
+			row[ix] = err.Error()
+			continue
+		}
+		row[ix] = value
+	}
+	return row
+}
+
+// decodeSpannerValue converts the wire representation of a Spanner value to a displayable Go value.
+// NULL is returned as Null, ARRAY as []interface{} and STRUCT as StructValue.
+func decodeSpannerValue(t *spannerpb.Type, v *structpb.Value) (interface{}, error) {
+	if v == nil {
+		return Null, nil
+	}
+	if _, isNull := v.Kind.(*structpb.Value_NullValue); isNull {
+		return Null, nil
+	}
+
+	switch t.GetCode() {
+	case spannerpb.TypeCode_BOOL:
+		return v.GetBoolValue(), nil
+	case spannerpb.TypeCode_INT64, spannerpb.TypeCode_ENUM:
+		i, err := strconv.ParseInt(v.GetStringValue(), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value", t.GetCode())
+		}
+		return i, nil
+	case spannerpb.TypeCode_FLOAT64:
+		return decodeFloat(v)
+	case spannerpb.TypeCode_FLOAT32:
+		f, err := decodeFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		return float32(f), nil
+	case spannerpb.TypeCode_NUMERIC:
+		return json.Number(v.GetStringValue()), nil
+	case spannerpb.TypeCode_JSON:
+		return JSONValue(v.GetStringValue()), nil
+	case spannerpb.TypeCode_BYTES, spannerpb.TypeCode_PROTO:
+		b, err := base64.StdEncoding.DecodeString(v.GetStringValue())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value", t.GetCode())
+		}
+		return hex.EncodeToString(b), nil
+	case spannerpb.TypeCode_TIMESTAMP:
+		ts, err := time.Parse(time.RFC3339Nano, v.GetStringValue())
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid TIMESTAMP value")
+		}
+		return ts.Format(time.RFC3339Nano), nil
+	case spannerpb.TypeCode_ARRAY:
+		values := v.GetListValue().GetValues()
+		array := make([]interface{}, len(values))
+		for i, element := range values {
+			decoded, err := decodeSpannerValue(t.GetArrayElementType(), element)
 			if err != nil {
-				row = append(row, err.Error())
+				return nil, err
 			}
-			row = append(row, hex.EncodeToString(v))
-		case spannerpb.TypeCode_TIMESTAMP:
-			var v *time.Time
-			err := r.Column(ix, &v)
+			array[i] = decoded
+		}
+		return array, nil
+	case spannerpb.TypeCode_STRUCT:
+		fields := t.GetStructType().GetFields()
+		values := v.GetListValue().GetValues()
+		if len(fields) != len(values) {
+			return nil, errors.Errorf("STRUCT has %d fields but %d values", len(fields), len(values))
+		}
+		record := make(StructValue, len(values))
+		for i, field := range fields {
+			decoded, err := decodeSpannerValue(field.GetType(), values[i])
 			if err != nil {
-				row = append(row, err.Error())
-			}
-			if v == nil {
-				row = append(row, "nil")
-			} else {
-				row = append(row, (*v).Format(time.RFC3339))
+				return nil, err
 			}
+			record[i] = StructField{Name: field.GetName(), Value: decoded}
+		}
+		return record, nil
+	}
 
-		default:
-			row = append(row, "Unknown type: "+r.ColumnType(ix).Code.String())
+	// STRING, DATE, INTERVAL, UUID and any type added later are transferred as plain strings
+	if s, ok := v.Kind.(*structpb.Value_StringValue); ok {
+		return s.StringValue, nil
+	}
+	return nil, errors.Errorf("Unknown type: %s", t.GetCode())
+}
+
+// decodeFloat handles both the numeric and the string ("NaN", "Infinity", "-Infinity") encoding of floats
+func decodeFloat(v *structpb.Value) (float64, error) {
+	switch k := v.Kind.(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue, nil
+	case *structpb.Value_StringValue:
+		switch k.StringValue {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
 		}
+		return 0, errors.Errorf("invalid float value %q", k.StringValue)
 	}
-	return row
+	return 0, errors.Errorf("invalid float value %v", v)
 }
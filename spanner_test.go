@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestConvertToRow(t *testing.T) {
+	typeOf := func(code spannerpb.TypeCode) *spannerpb.Type {
+		return &spannerpb.Type{Code: code}
+	}
+	arrayOf := func(element *spannerpb.Type) *spannerpb.Type {
+		return &spannerpb.Type{Code: spannerpb.TypeCode_ARRAY, ArrayElementType: element}
+	}
+	structOf := func(fields ...*spannerpb.StructType_Field) *spannerpb.Type {
+		return &spannerpb.Type{Code: spannerpb.TypeCode_STRUCT, StructType: &spannerpb.StructType{Fields: fields}}
+	}
+	field := func(name string, t *spannerpb.Type) *spannerpb.StructType_Field {
+		return &spannerpb.StructType_Field{Name: name, Type: t}
+	}
+	list := func(values ...*structpb.Value) *structpb.Value {
+		return structpb.NewListValue(&structpb.ListValue{Values: values})
+	}
+	str := structpb.NewStringValue
+	null := structpb.NewNullValue()
+
+	tests := []struct {
+		name     string
+		t        *spannerpb.Type
+		value    *structpb.Value
+		expected interface{}
+	}{
+		{"bool", typeOf(spannerpb.TypeCode_BOOL), structpb.NewBoolValue(true), true},
+		{"null bool", typeOf(spannerpb.TypeCode_BOOL), null, Null},
+		{"int64", typeOf(spannerpb.TypeCode_INT64), str("-42"), int64(-42)},
+		{"null int64", typeOf(spannerpb.TypeCode_INT64), null, Null},
+		{"float64", typeOf(spannerpb.TypeCode_FLOAT64), structpb.NewNumberValue(1.5), 1.5},
+		{"float64 infinity", typeOf(spannerpb.TypeCode_FLOAT64), str("-Infinity"), math.Inf(-1)},
+		{"float32", typeOf(spannerpb.TypeCode_FLOAT32), structpb.NewNumberValue(0.25), float32(0.25)},
+		{"string", typeOf(spannerpb.TypeCode_STRING), str("nil"), "nil"},
+		{"null string", typeOf(spannerpb.TypeCode_STRING), null, Null},
+		{"bytes", typeOf(spannerpb.TypeCode_BYTES), str("AQL/"), "0102ff"},
+		{"timestamp", typeOf(spannerpb.TypeCode_TIMESTAMP), str("2024-01-02T03:04:05.123456Z"), "2024-01-02T03:04:05.123456Z"},
+		{"date", typeOf(spannerpb.TypeCode_DATE), str("2024-01-02"), "2024-01-02"},
+		{"numeric", typeOf(spannerpb.TypeCode_NUMERIC), str("123.456000001"), json.Number("123.456000001")},
+		{"json", typeOf(spannerpb.TypeCode_JSON), str(`{"a":[1,2]}`), JSONValue(`{"a":[1,2]}`)},
+		{"null json", typeOf(spannerpb.TypeCode_JSON), null, Null},
+		{"proto", typeOf(spannerpb.TypeCode_PROTO), str("CAE="), "0801"},
+		{"enum", typeOf(spannerpb.TypeCode_ENUM), str("3"), int64(3)},
+		{"interval", typeOf(spannerpb.TypeCode_INTERVAL), str("P1Y2M3DT4H5M6.5S"), "P1Y2M3DT4H5M6.5S"},
+		{"uuid", &spannerpb.Type{Code: 17}, str("4f1b5c4e-0e39-4b0e-9b3a-7a1c2a7f0d11"), "4f1b5c4e-0e39-4b0e-9b3a-7a1c2a7f0d11"},
+		{"int64 array", arrayOf(typeOf(spannerpb.TypeCode_INT64)), list(str("1"), null, str("3")), []interface{}{int64(1), Null, int64(3)}},
+		{"null array", arrayOf(typeOf(spannerpb.TypeCode_STRING)), null, Null},
+		{"empty array", arrayOf(typeOf(spannerpb.TypeCode_STRING)), list(), []interface{}{}},
+		{"date array", arrayOf(typeOf(spannerpb.TypeCode_DATE)), list(str("2024-01-02")), []interface{}{"2024-01-02"}},
+		{"bytes array", arrayOf(typeOf(spannerpb.TypeCode_BYTES)), list(str("AQ=="), null), []interface{}{"01", Null}},
+		{"numeric array", arrayOf(typeOf(spannerpb.TypeCode_NUMERIC)), list(str("1.5")), []interface{}{json.Number("1.5")}},
+		{"json array", arrayOf(typeOf(spannerpb.TypeCode_JSON)), list(str("true")), []interface{}{JSONValue("true")}},
+		{"bool array", arrayOf(typeOf(spannerpb.TypeCode_BOOL)), list(structpb.NewBoolValue(false)), []interface{}{false}},
+		{"float array", arrayOf(typeOf(spannerpb.TypeCode_FLOAT64)), list(structpb.NewNumberValue(2), str("Infinity")), []interface{}{float64(2), math.Inf(1)}},
+		{
+			"struct",
+			structOf(field("id", typeOf(spannerpb.TypeCode_INT64)), field("name", typeOf(spannerpb.TypeCode_STRING))),
+			list(str("1"), null),
+			StructValue{{Name: "id", Value: int64(1)}, {Name: "name", Value: Null}},
+		},
+		{
+			"nested struct",
+			structOf(field("inner", structOf(field("tags", arrayOf(typeOf(spannerpb.TypeCode_STRING)))))),
+			list(list(list(str("a"), str("b")))),
+			StructValue{{Name: "inner", Value: StructValue{{Name: "tags", Value: []interface{}{"a", "b"}}}}},
+		},
+		{
+			"array of struct",
+			arrayOf(structOf(field("", typeOf(spannerpb.TypeCode_INT64)), field("b", typeOf(spannerpb.TypeCode_BOOL)))),
+			list(list(str("1"), structpb.NewBoolValue(true)), list(str("2"), null)),
+			[]interface{}{
+				StructValue{{Name: "", Value: int64(1)}, {Name: "b", Value: true}},
+				StructValue{{Name: "", Value: int64(2)}, {Name: "b", Value: Null}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			row, err := spanner.NewRow([]string{"col"}, []interface{}{spanner.GenericColumnValue{Type: tc.t, Value: tc.value}})
+			require.NoError(t, err)
+			converted := convertToRow(row)
+			require.Len(t, converted, 1)
+			require.Equal(t, tc.expected, converted[0])
+		})
+	}
+}
+
+func TestConvertToRowNaN(t *testing.T) {
+	row, err := spanner.NewRow([]string{"col"}, []interface{}{spanner.GenericColumnValue{
+		Type:  &spannerpb.Type{Code: spannerpb.TypeCode_FLOAT64},
+		Value: structpb.NewStringValue("NaN"),
+	}})
+	require.NoError(t, err)
+	require.True(t, math.IsNaN(convertToRow(row)[0].(float64)))
+}
+
+func TestSpannerStats(t *testing.T) {
+	require.Nil(t, spannerStats(nil))
+	require.Equal(t, []QueryStat{
+		{Name: "rows scanned", Value: "10"},
+		{Name: "CPU time", Value: "1.23 msecs"},
+		{Name: "optimizer version", Value: "7"},
+	}, spannerStats(map[string]interface{}{
+		"optimizer_version": "7",
+		"rows_scanned":      "10",
+		"cpu_time":          "1.23 msecs",
+		"query_text":        "SELECT 1",
+	}))
+}
+
+func TestIsDDL(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"CREATE TABLE t (id INT64) PRIMARY KEY (id)", true},
+		{"create index i on t (a)", true},
+		{"ALTER TABLE t ADD COLUMN c STRING(MAX)", true},
+		{"DROP TABLE t", true},
+		{"  -- remove the index\n DROP INDEX i", true},
+		{"/* comment */ GRANT SELECT ON TABLE t TO ROLE r", true},
+		{"REVOKE SELECT ON TABLE t FROM ROLE r", true},
+		{"RENAME TABLE a TO b", true},
+		{"ANALYZE", true},
+		{"SELECT 1", false},
+		{"SELECT 'CREATE TABLE t'", false},
+		{"INSERT INTO t (id) VALUES (1)", false},
+		{"WITH d AS (SELECT 1) SELECT * FROM d", false},
+		{"EXPLAIN DROP TABLE t", false},
+		{"-- CREATE TABLE t", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, isDDL(test.query, GoogleSQL), test.query)
+	}
+
+	// # starts a comment only in GoogleSQL
+	require.True(t, isDDL("# comment\nDROP TABLE t", GoogleSQL))
+	require.False(t, isDDL("# comment\nDROP TABLE t", PostgreSQL))
+	require.False(t, isDDL("SELECT $$DROP$$", PostgreSQL))
+}
+
+func TestResultBuffer(t *testing.T) {
+	defer func() { outputFormat = "" }()
+	outputFormat = string(CSVFormat)
+	out := captureOutput(func() {
+		var result *resultBuffer
+		// the aborted attempt of the transaction is discarded
+		for range 2 {
+			result = &resultBuffer{}
+			result.SetHeader([]string{"id"})
+			result.AppendRow([]interface{}{int64(1)})
+		}
+		writer := GetResultWriter(outputFormat)
+		result.writeTo(writer)
+		writer.Render()
+
+		// DML without result set
+		(&resultBuffer{}).writeTo(writer)
+	})
+	require.Equal(t, "id\n1\n", out)
+}
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	require.True(t, isReadOnlyQuery([]string{"SELECT 1", "GRAPH g MATCH (n) RETURN n"}, GoogleSQL))
+	require.True(t, isReadOnlyQuery([]string{"SHOW database.dialect"}, PostgreSQL))
+	require.False(t, isReadOnlyQuery([]string{"SELECT 1", "DELETE FROM t WHERE id = 1"}, GoogleSQL))
+	require.False(t, isReadOnlyQuery([]string{"CREATE TABLE t (id INT64) PRIMARY KEY (id)"}, GoogleSQL))
+}
+
+// TestDescribeTable runs against the Spanner emulator, it's skipped if SPANNER_EMULATOR_HOST is not set
+func TestDescribeTable(t *testing.T) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("SPANNER_EMULATOR_HOST is not set")
+	}
+	ctx := context.Background()
+	name := fmt.Sprintf("projects/test-project/instances/test-instance/databases/describe-%d", time.Now().UnixNano()%1_000_000)
+	require.NoError(t, createEmulatorDatabase(ctx, name))
+	client, err := NewSpannerClient(ctx, name, "test", 0, time.Time{}, false, "")
+	require.NoError(t, err)
+	defer client.Close()
+
+	defer func() { outputFormat = "" }()
+	outputFormat = string(CSVFormat)
+	require.NoError(t, client.ExecuteDDL(ctx, []string{
+		"CREATE TABLE Customers (Region STRING(10) NOT NULL, Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Region, Id)",
+		"CREATE TABLE Orders (Id INT64 NOT NULL, CustomerId INT64, CustomerRegion STRING(10), " +
+			"CONSTRAINT FK_Customer FOREIGN KEY (CustomerId, CustomerRegion) REFERENCES Customers (Id, Region)) PRIMARY KEY (Id)",
+		"CREATE INDEX OrdersByCustomer ON Orders (CustomerRegion, CustomerId DESC) STORING (Id)",
+	}))
+
+	out := captureOutput(func() {
+		require.NoError(t, client.DescribeTable(ctx, "Orders"))
+	})
+	sections := strings.Split(strings.TrimSpace(out), "\n\n")
+	require.Len(t, sections, 4, out)
+	require.Equal(t, "Table,Primary Key,Parent,On Delete\nOrders,Id,,", sections[0])
+	require.Equal(t, ""+
+		"Column,Type,Nullable,Default,Generated,Stored\n"+
+		"Id,INT64,NO,,,\n"+
+		"CustomerId,INT64,YES,,,\n"+
+		"CustomerRegion,STRING(10),YES,,,", sections[1])
+	require.Equal(t, ""+
+		"Index,Columns,Unique,Null Filtered,Interleaved In,Storing\n"+
+		"OrdersByCustomer,\"CustomerRegion, CustomerId DESC\",false,false,,", sections[2])
+	// the referenced columns are paired with the columns of the foreign key, not sorted by name
+	require.Equal(t, ""+
+		"Foreign Key,Columns,Referenced Table,Referenced Columns,On Delete\n"+
+		"FK_Customer,\"CustomerId, CustomerRegion\",Customers,\"Id, Region\",NO ACTION", sections[3])
+
+	require.Error(t, client.DescribeTable(ctx, "Missing"))
+}
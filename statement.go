@@ -0,0 +1,533 @@
+package main
+
+import (
+	"strings"
+)
+
+// Dialect is the SQL dialect used to tokenize the statements
+type Dialect int
+
+const (
+	// GoogleSQL is the dialect of BigQuery and the default dialect of Spanner
+	GoogleSQL Dialect = iota
+	// PostgreSQL is the dialect of Spanner databases created with the PostgreSQL interface
+	PostgreSQL
+)
+
+type tokenKind int
+
+const (
+	tokenWhitespace tokenKind = iota
+	tokenComment
+	tokenWord
+	tokenQuotedIdentifier
+	tokenString
+	tokenNumber
+	tokenParam
+	tokenSymbol
+	tokenSemicolon
+)
+
+// token is a lexical element of a SQL statement
+type token struct {
+	kind tokenKind
+	text string
+	// start is the byte offset of the token in the tokenized text
+	start int
+	// unterminated is true for strings, quoted identifiers and comments without closing delimiter
+	unterminated bool
+}
+
+// lexer splits SQL text to tokens. It understands enough of the syntax to find the boundaries of
+// string literals, quoted identifiers and comments, it doesn't validate the statements.
+type lexer struct {
+	sql     string
+	dialect Dialect
+	pos     int
+}
+
+// tokenize returns all the tokens of the SQL text, including whitespace and comments
+func tokenize(sql string, dialect Dialect) []token {
+	l := &lexer{sql: sql, dialect: dialect}
+	var tokens []token
+	for l.pos < len(l.sql) {
+		tokens = append(tokens, l.next())
+	}
+	return tokens
+}
+
+func (l *lexer) next() token {
+	start := l.pos
+	c := l.sql[l.pos]
+	kind, unterminated := l.scan(c)
+	return token{
+		kind:         kind,
+		text:         l.sql[start:l.pos],
+		start:        start,
+		unterminated: unterminated,
+	}
+}
+
+// scan consumes the token starting with c, and returns its kind
+func (l *lexer) scan(c byte) (tokenKind, bool) {
+	start := l.pos
+	switch {
+	case isSpace(c):
+		for l.pos < len(l.sql) && isSpace(l.sql[l.pos]) {
+			l.pos++
+		}
+		return tokenWhitespace, false
+	case strings.HasPrefix(l.sql[l.pos:], "--"), c == '#' && l.dialect == GoogleSQL:
+		l.skipLine()
+		return tokenComment, false
+	case strings.HasPrefix(l.sql[l.pos:], "/*"):
+		return tokenComment, !l.skipBlockComment()
+	case c == '\'' || c == '"' && l.dialect == GoogleSQL:
+		return tokenString, !l.skipQuoted(l.dialect == GoogleSQL)
+	case c == '"':
+		return tokenQuotedIdentifier, !l.skipQuoted(false)
+	case c == '`' && l.dialect == GoogleSQL:
+		return tokenQuotedIdentifier, !l.skipQuoted(true)
+	case c == '$' && l.dialect == PostgreSQL:
+		if l.pos+1 < len(l.sql) && isDigit(l.sql[l.pos+1]) {
+			l.pos++
+			for l.pos < len(l.sql) && isDigit(l.sql[l.pos]) {
+				l.pos++
+			}
+			return tokenParam, false
+		}
+		if tag, ok := l.dollarTag(); ok {
+			return tokenString, !l.skipDollarQuoted(tag)
+		}
+	case c == '@':
+		l.pos++
+		for l.pos < len(l.sql) && (l.sql[l.pos] == '@' || isWordChar(l.sql[l.pos], l.dialect)) {
+			l.pos++
+		}
+		return tokenParam, false
+	case isDigit(c) || c == '.' && l.pos+1 < len(l.sql) && isDigit(l.sql[l.pos+1]):
+		l.skipNumber()
+		return tokenNumber, false
+	case isWordStart(c):
+		for l.pos < len(l.sql) && isWordChar(l.sql[l.pos], l.dialect) {
+			l.pos++
+		}
+		if l.pos < len(l.sql) {
+			if kind, unterminated, ok := l.prefixedString(l.sql[start:l.pos]); ok {
+				return kind, unterminated
+			}
+		}
+		return tokenWord, false
+	case c == ';':
+		l.pos++
+		return tokenSemicolon, false
+	}
+	l.pos++
+	return tokenSymbol, false
+}
+
+// prefixedString handles the string literals with prefix: r'', b'', rb'' (GoogleSQL) and E'' (PostgreSQL)
+func (l *lexer) prefixedString(prefix string) (kind tokenKind, unterminated bool, ok bool) {
+	quote := l.sql[l.pos]
+	switch l.dialect {
+	case GoogleSQL:
+		if quote != '\'' && quote != '"' {
+			return 0, false, false
+		}
+		switch strings.ToLower(prefix) {
+		case "r", "rb", "br", "b":
+			// escape sequences are not interpreted in raw strings, but a backslash still pairs with the next
+			// character: r'\'' doesn't end at the second quote, and a raw string can't end with a single backslash
+			return tokenString, !l.skipQuoted(true), true
+		}
+	case PostgreSQL:
+		if quote == '\'' && strings.EqualFold(prefix, "e") {
+			return tokenString, !l.skipQuoted(true), true
+		}
+	}
+	return 0, false, false
+}
+
+// skipQuoted consumes a quoted string or identifier (GoogleSQL triple-quoted strings included).
+// Returns false if the closing quote is missing.
+func (l *lexer) skipQuoted(backslashEscape bool) bool {
+	quote := l.sql[l.pos]
+	delimiter := string(quote)
+	if l.dialect == GoogleSQL && quote != '`' && strings.HasPrefix(l.sql[l.pos:], strings.Repeat(delimiter, 3)) {
+		delimiter = strings.Repeat(delimiter, 3)
+	}
+	l.pos += len(delimiter)
+	for l.pos < len(l.sql) {
+		switch {
+		case backslashEscape && l.sql[l.pos] == '\\':
+			l.pos += 2
+		case strings.HasPrefix(l.sql[l.pos:], delimiter):
+			l.pos += len(delimiter)
+			// PostgreSQL escapes the quote character by doubling it
+			if l.dialect == PostgreSQL && l.pos < len(l.sql) && l.sql[l.pos] == quote {
+				l.pos++
+				continue
+			}
+			return true
+		default:
+			l.pos++
+		}
+	}
+	l.pos = len(l.sql)
+	return false
+}
+
+// dollarTag returns the $tag$ opening a PostgreSQL dollar-quoted string at the current position
+func (l *lexer) dollarTag() (string, bool) {
+	end := l.pos + 1
+	for end < len(l.sql) && l.sql[end] != '$' {
+		if !isWordChar(l.sql[end], GoogleSQL) || end == l.pos+1 && isDigit(l.sql[end]) {
+			return "", false
+		}
+		end++
+	}
+	if end >= len(l.sql) {
+		return "", false
+	}
+	return l.sql[l.pos : end+1], true
+}
+
+func (l *lexer) skipDollarQuoted(tag string) bool {
+	l.pos += len(tag)
+	end := strings.Index(l.sql[l.pos:], tag)
+	if end < 0 {
+		l.pos = len(l.sql)
+		return false
+	}
+	l.pos += end + len(tag)
+	return true
+}
+
+func (l *lexer) skipLine() {
+	end := strings.IndexByte(l.sql[l.pos:], '\n')
+	if end < 0 {
+		l.pos = len(l.sql)
+		return
+	}
+	l.pos += end
+}
+
+// skipBlockComment consumes a /* */ comment. PostgreSQL comments can be nested.
+func (l *lexer) skipBlockComment() bool {
+	depth := 0
+	for l.pos < len(l.sql) {
+		switch {
+		case strings.HasPrefix(l.sql[l.pos:], "/*") && (depth == 0 || l.dialect == PostgreSQL):
+			depth++
+			l.pos += 2
+		case strings.HasPrefix(l.sql[l.pos:], "*/"):
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return true
+			}
+		default:
+			l.pos++
+		}
+	}
+	return false
+}
+
+func (l *lexer) skipNumber() {
+	for l.pos < len(l.sql) {
+		c := l.sql[l.pos]
+		switch {
+		case isDigit(c) || c == '.' || c == '_' || isLetter(c):
+			l.pos++
+		case (c == '+' || c == '-') && (l.sql[l.pos-1] == 'e' || l.sql[l.pos-1] == 'E'):
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isWordStart checks if c can be the first byte of a keyword or an identifier (non-ASCII included)
+func isWordStart(c byte) bool {
+	return isLetter(c) || c == '_' || c >= 0x80
+}
+
+func isWordChar(c byte, dialect Dialect) bool {
+	return isWordStart(c) || isDigit(c) || c == '$' && dialect == PostgreSQL
+}
+
+// SplitStatements splits the script at the semicolons which are outside of string literals, quoted
+// identifiers, comments and GoogleSQL script blocks (BEGIN ... END, IF ... END IF, procedure bodies, ...).
+// Statements which contain only whitespace and comments are dropped.
+func SplitStatements(script string, dialect Dialect) []string {
+	statements, rest := splitStatements(script, dialect)
+	if rest = strings.TrimSpace(rest); hasCode(tokenize(rest, dialect)) {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// splitStatements returns the semicolon terminated statements (without the semicolon),
+// and the remaining text after the last terminating semicolon.
+func splitStatements(script string, dialect Dialect) (statements []string, rest string) {
+	start := 0
+	var current []token
+	var blocks scriptBlocks
+	tokens := tokenize(script, dialect)
+	for i, t := range tokens {
+		if dialect == GoogleSQL {
+			blocks.next(tokens, i)
+		}
+		if t.kind != tokenSemicolon || len(blocks.open) > 0 {
+			current = append(current, t)
+			continue
+		}
+		if hasCode(current) {
+			statements = append(statements, strings.TrimSpace(script[start:t.start]))
+		}
+		start = t.start + 1
+		current = nil
+	}
+	return statements, script[start:]
+}
+
+// scriptBlocks tracks the nesting of the GoogleSQL script blocks, which contain semicolon terminated statements:
+// BEGIN ... END, IF ... END IF, LOOP ... END LOOP, WHILE ... END WHILE, FOR ... END FOR, REPEAT ... END REPEAT and
+// CASE ... END (the CASE expressions are tracked too, as they also end with END).
+type scriptBlocks struct {
+	// open are the keywords of the open blocks, empty for a CASE expression
+	open []string
+	// previous is the previous code token (semicolons included)
+	previous token
+	// procedure is set if the current statement creates a procedure, its body is a BEGIN ... END block
+	procedure bool
+}
+
+// scriptBlockStatements are the script statements (except BEGIN) which end with END, e.g. END IF
+var scriptBlockStatements = map[string]bool{
+	"IF": true, "LOOP": true, "WHILE": true, "FOR": true, "REPEAT": true, "CASE": true,
+}
+
+// scriptStatementStarts are the keywords which are followed by a statement in a script block
+var scriptStatementStarts = map[string]bool{
+	"BEGIN": true, "THEN": true, "ELSE": true, "DO": true, "LOOP": true, "REPEAT": true,
+}
+
+// next processes the i-th token
+func (b *scriptBlocks) next(tokens []token, i int) {
+	t := tokens[i]
+	if t.kind == tokenWhitespace || t.kind == tokenComment {
+		return
+	}
+	word := ""
+	if t.kind == tokenWord {
+		word = strings.ToUpper(t.text)
+	}
+	switch {
+	case word == "BEGIN" && (b.statementStart() || b.procedure && len(b.open) == 0) && !transactionStart(tokens[i+1:]):
+		b.open = append(b.open, word)
+	case scriptBlockStatements[word] && b.statementStart():
+		b.open = append(b.open, word)
+	case word == "CASE" && !strings.EqualFold(b.previous.text, "END"):
+		b.open = append(b.open, "")
+	case word == "END" && len(b.open) > 0:
+		b.open = b.open[:len(b.open)-1]
+	case word == "PROCEDURE" && len(b.open) == 0:
+		b.procedure = true
+	case t.kind == tokenSemicolon && len(b.open) == 0:
+		b.procedure = false
+	}
+	b.previous = t
+}
+
+// statementStart checks if the current token is the first one of a statement (a label may precede it)
+func (b *scriptBlocks) statementStart() bool {
+	switch {
+	case b.previous.kind == tokenSemicolon, b.previous.text == "", b.previous.text == ":":
+		return true
+	case b.previous.kind != tokenWord:
+		return false
+	}
+	// THEN and ELSE of a CASE expression are followed by expressions
+	inExpression := len(b.open) > 0 && b.open[len(b.open)-1] == ""
+	return scriptStatementStarts[strings.ToUpper(b.previous.text)] && !inExpression
+}
+
+// transactionStart checks if BEGIN is followed by the rest of a transaction control statement (BEGIN TRANSACTION),
+// not by the statements of a block
+func transactionStart(tokens []token) bool {
+	for _, t := range tokens {
+		switch {
+		case t.kind == tokenWhitespace || t.kind == tokenComment:
+			continue
+		case t.kind == tokenSemicolon:
+			return true
+		}
+		return t.kind == tokenWord && (strings.EqualFold(t.text, "TRANSACTION") || strings.EqualFold(t.text, "READ"))
+	}
+	return true
+}
+
+// hasCode checks if there is anything in the tokens except whitespace and comments
+func hasCode(tokens []token) bool {
+	for _, t := range tokens {
+		if t.kind != tokenWhitespace && t.kind != tokenComment {
+			return true
+		}
+	}
+	return false
+}
+
+// statementInfo is the classification of a statement, based on its tokens
+type statementInfo struct {
+	// keyword is the upper case verb of the statement, e.g. DELETE for WITH ... DELETE (empty without code)
+	keyword string
+	// where is true if the main statement has a WHERE clause, which is not trivially true (e.g. WHERE TRUE)
+	where bool
+	// nestedDML is true if a parenthesized part of the statement modifies rows,
+	// e.g. WITH d AS (DELETE ... RETURNING *) SELECT ... (PostgreSQL)
+	nestedDML bool
+}
+
+// dmlKeywords are the verbs of the statements which modify rows
+var dmlKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true,
+}
+
+// ddlKeywords are the verbs of the schema changes
+var ddlKeywords = map[string]bool{
+	"CREATE": true, "ALTER": true, "DROP": true, "GRANT": true, "REVOKE": true, "RENAME": true, "ANALYZE": true,
+}
+
+// readKeywords are the verbs of the statements which can't modify the database. Everything else, including
+// the BigQuery scripts (BEGIN ... END, IF, DECLARE, SET, ...) and the procedure calls, may modify it.
+var readKeywords = map[string]bool{
+	"SELECT": true, "EXPLAIN": true, "SHOW": true, "GRAPH": true,
+}
+
+// classifyStatement finds the verb of the statement, skipping the comments, the common table expressions of WITH
+// and the EXPLAIN prefix (EXPLAIN ANALYZE is classified by the explained statement, as it's executed)
+func classifyStatement(sql string, dialect Dialect) statementInfo {
+	var words []token
+	for _, t := range tokenize(sql, dialect) {
+		if t.kind != tokenWhitespace && t.kind != tokenComment {
+			words = append(words, t)
+		}
+	}
+
+	var info statementInfo
+	depth := 0
+	// hint is the depth of the braces of the statement hints (Spanner), e.g. @{USE_ADDITIONAL_PARALLELISM=TRUE}
+	hint := 0
+	inWith := false
+	where := -1
+	for i := 0; i < len(words); i++ {
+		t := words[i]
+		switch {
+		case t.text == "{":
+			hint++
+			continue
+		case t.text == "}":
+			hint--
+			continue
+		case hint > 0:
+			continue
+		case t.text == "(":
+			depth++
+			continue
+		case t.text == ")":
+			depth--
+			continue
+		case t.kind == tokenWord && i > 0 && words[i-1].text == "(" && dmlKeywords[strings.ToUpper(t.text)]:
+			info.nestedDML = true
+		}
+		if t.kind != tokenWord || depth > 0 && (inWith || info.keyword != "") {
+			// only the words of the main statement are checked (including a leading parenthesized query)
+			continue
+		}
+		word := strings.ToUpper(t.text)
+		if info.keyword != "" {
+			if word == "WHERE" && where < 0 {
+				where = i
+			}
+			continue
+		}
+		switch {
+		case word == "WITH" && i == 0:
+			inWith = true
+		case word == "EXPLAIN" && i == 0:
+			if i+1 < len(words) && strings.EqualFold(words[i+1].text, "ANALYZE") {
+				i++
+				continue
+			}
+			info.keyword = word
+		case inWith && word != "SELECT" && !dmlKeywords[word]:
+			// name of a common table expression, AS or RECURSIVE
+		default:
+			info.keyword = word
+		}
+	}
+	info.where = where >= 0 && !trivialCondition(words[where+1:])
+	return info
+}
+
+// trivialCondition checks if the condition of a WHERE clause is always true: TRUE or a comparison of the same
+// literals (1=1). GoogleSQL requires WHERE for DELETE and UPDATE, so these are used to modify all the rows.
+func trivialCondition(words []token) bool {
+	var condition []token
+	for _, t := range words {
+		if t.kind == tokenSemicolon || t.kind == tokenWord && (strings.EqualFold(t.text, "RETURNING") || strings.EqualFold(t.text, "THEN")) {
+			break
+		}
+		if t.text != "(" && t.text != ")" {
+			condition = append(condition, t)
+		}
+	}
+	switch len(condition) {
+	case 1:
+		return strings.EqualFold(condition[0].text, "TRUE")
+	case 3:
+		literal := condition[0].kind == tokenNumber || condition[0].kind == tokenString
+		return literal && condition[1].text == "=" && condition[0].text == condition[2].text
+	}
+	return false
+}
+
+// isDML checks if the statement modifies rows (INSERT, UPDATE, DELETE, MERGE, ...)
+func (s statementInfo) isDML() bool {
+	return dmlKeywords[s.keyword]
+}
+
+// isDDL checks if the statement is a schema change (CREATE, ALTER, DROP, ...)
+func (s statementInfo) isDDL() bool {
+	return ddlKeywords[s.keyword]
+}
+
+// isReadOnly checks if the statement can't modify the database: only queries (Spanner Graph queries included) and
+// EXPLAIN (EXPLAIN ANALYZE of a query) are accepted, any other statement may modify it
+func (s statementInfo) isReadOnly() bool {
+	return readKeywords[s.keyword] && !s.nestedDML
+}
+
+// isDestructive checks if the statement drops or truncates objects, or modifies all the rows of a table
+func (s statementInfo) isDestructive() bool {
+	switch s.keyword {
+	case "DROP", "TRUNCATE":
+		return true
+	case "DELETE", "UPDATE":
+		return !s.where
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	checkSplit := func(t *testing.T, script string, expected ...string) {
+		require.Equal(t, expected, SplitStatements(script, GoogleSQL), script)
+	}
+
+	t.Run("simple", func(t *testing.T) {
+		checkSplit(t, "SELECT 1; SELECT 2;", "SELECT 1", "SELECT 2")
+		checkSplit(t, "SELECT 1; SELECT 2", "SELECT 1", "SELECT 2")
+		checkSplit(t, "  SELECT 1  ;;\n;  ", "SELECT 1")
+		checkSplit(t, "")
+		checkSplit(t, " ; ")
+	})
+
+	t.Run("strings", func(t *testing.T) {
+		checkSplit(t, "SELECT 'a;b'; SELECT 2", "SELECT 'a;b'", "SELECT 2")
+		checkSplit(t, `SELECT "a;b"; SELECT 2`, `SELECT "a;b"`, "SELECT 2")
+		checkSplit(t, `SELECT 'it\'s;'; SELECT 2`, `SELECT 'it\'s;'`, "SELECT 2")
+		checkSplit(t, `SELECT "\\"; SELECT 2`, `SELECT "\\"`, "SELECT 2")
+		checkSplit(t, "SELECT 'a'';'", "SELECT 'a'';'")
+	})
+
+	t.Run("triple-quoted strings", func(t *testing.T) {
+		checkSplit(t, "SELECT '''a;\n'b';\n'''; SELECT 2", "SELECT '''a;\n'b';\n'''", "SELECT 2")
+		checkSplit(t, `SELECT """a";"b"""; SELECT 2`, `SELECT """a";"b"""`, "SELECT 2")
+		checkSplit(t, `SELECT ''''; SELECT 2`, `SELECT ''''; SELECT 2`)
+	})
+
+	t.Run("raw and bytes strings", func(t *testing.T) {
+		checkSplit(t, `SELECT r'\\'; SELECT 2`, `SELECT r'\\'`, "SELECT 2")
+		checkSplit(t, `SELECT R"a\";b"; SELECT 2`, `SELECT R"a\";b"`, "SELECT 2")
+		checkSplit(t, `SELECT r'\'; SELECT 2`, `SELECT r'\'; SELECT 2`)
+		checkSplit(t, `SELECT b'\';'; SELECT 2`, `SELECT b'\';'`, "SELECT 2")
+		checkSplit(t, `SELECT rb'\';'; SELECT br'\\'`, `SELECT rb'\';'`, `SELECT br'\\'`)
+		checkSplit(t, `SELECT rb'''a;b'''`, `SELECT rb'''a;b'''`)
+		checkSplit(t, `SELECT bar';'`, `SELECT bar';'`)
+	})
+
+	t.Run("script blocks", func(t *testing.T) {
+		checkSplit(t, "BEGIN SELECT 1; SELECT 2; END; SELECT 3", "BEGIN SELECT 1; SELECT 2; END", "SELECT 3")
+		checkSplit(t, "BEGIN\n  BEGIN SELECT 1; END;\n  SELECT 2;\nEXCEPTION WHEN ERROR THEN\n  SELECT 3;\nEND;",
+			"BEGIN\n  BEGIN SELECT 1; END;\n  SELECT 2;\nEXCEPTION WHEN ERROR THEN\n  SELECT 3;\nEND")
+		checkSplit(t, "CREATE OR REPLACE PROCEDURE d.p(x INT64) BEGIN SELECT x; SELECT x + 1; END; CALL d.p(1)",
+			"CREATE OR REPLACE PROCEDURE d.p(x INT64) BEGIN SELECT x; SELECT x + 1; END", "CALL d.p(1)")
+		checkSplit(t, "IF x > 1 THEN SELECT 1; ELSEIF x > 0 THEN SELECT 2; ELSE SELECT 3; END IF; SELECT 4",
+			"IF x > 1 THEN SELECT 1; ELSEIF x > 0 THEN SELECT 2; ELSE SELECT 3; END IF", "SELECT 4")
+		checkSplit(t, "l: LOOP IF (i > 2) THEN LEAVE l; END IF; SET i = i + 1; END LOOP l; SELECT i",
+			"l: LOOP IF (i > 2) THEN LEAVE l; END IF; SET i = i + 1; END LOOP l", "SELECT i")
+		checkSplit(t, "WHILE i < 3 DO SET i = i + 1; END WHILE; REPEAT SET i = i - 1; UNTIL i = 0 END REPEAT",
+			"WHILE i < 3 DO SET i = i + 1; END WHILE", "REPEAT SET i = i - 1; UNTIL i = 0 END REPEAT")
+		checkSplit(t, "FOR r IN (SELECT 1 AS n) DO SELECT r.n; END FOR; SELECT 2", "FOR r IN (SELECT 1 AS n) DO SELECT r.n; END FOR", "SELECT 2")
+		checkSplit(t, "CASE x WHEN 1 THEN IF y THEN SELECT 1; END IF; ELSE SELECT CASE WHEN y THEN IF(z, 1, 2) END; END CASE; SELECT 3",
+			"CASE x WHEN 1 THEN IF y THEN SELECT 1; END IF; ELSE SELECT CASE WHEN y THEN IF(z, 1, 2) END; END CASE", "SELECT 3")
+		// the expressions are not blocks
+		checkSplit(t, "SELECT CASE WHEN a THEN IF(b, 1, 2) ELSE 3 END; SELECT 2", "SELECT CASE WHEN a THEN IF(b, 1, 2) ELSE 3 END", "SELECT 2")
+		checkSplit(t, "SELECT IF(a, 1, 2) AS begin; SELECT 2", "SELECT IF(a, 1, 2) AS begin", "SELECT 2")
+		// transaction control statements
+		checkSplit(t, "BEGIN; SELECT 1; COMMIT", "BEGIN", "SELECT 1", "COMMIT")
+		checkSplit(t, "BEGIN TRANSACTION; SELECT 1; COMMIT TRANSACTION", "BEGIN TRANSACTION", "SELECT 1", "COMMIT TRANSACTION")
+		checkSplit(t, "BEGIN READ ONLY; SELECT 1", "BEGIN READ ONLY", "SELECT 1")
+	})
+
+	t.Run("quoted identifiers", func(t *testing.T) {
+		checkSplit(t, "SELECT `a;b` FROM `t;`; SELECT 2", "SELECT `a;b` FROM `t;`", "SELECT 2")
+		checkSplit(t, "SELECT `a\\`;b`", "SELECT `a\\`;b`")
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		checkSplit(t, "SELECT 1 -- first;\n; SELECT 2", "SELECT 1 -- first;", "SELECT 2")
+		checkSplit(t, "SELECT 1 # first;\n; SELECT 2", "SELECT 1 # first;", "SELECT 2")
+		checkSplit(t, "SELECT /* ; */ 1; SELECT 2", "SELECT /* ; */ 1", "SELECT 2")
+		checkSplit(t, "SELECT /* /* ; */ 1; SELECT 2", "SELECT /* /* ; */ 1", "SELECT 2")
+		checkSplit(t, "-- header;\nSELECT 1;\n-- footer;\n", "-- header;\nSELECT 1")
+		checkSplit(t, "SELECT 1; /* only a comment */ ;", "SELECT 1")
+		checkSplit(t, "SELECT 1 - -1; SELECT 2", "SELECT 1 - -1", "SELECT 2")
+		checkSplit(t, "SELECT '--'; SELECT 2", "SELECT '--'", "SELECT 2")
+	})
+
+	t.Run("unterminated", func(t *testing.T) {
+		checkSplit(t, "SELECT 'a; SELECT 2", "SELECT 'a; SELECT 2")
+		checkSplit(t, "SELECT 1; SELECT /* a; b", "SELECT 1", "SELECT /* a; b")
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		checkSplit(t, "SELECT @p1; SELECT @@a.b", "SELECT @p1", "SELECT @@a.b")
+	})
+}
+
+func TestSplitStatementsPostgreSQL(t *testing.T) {
+	checkSplit := func(t *testing.T, script string, expected ...string) {
+		require.Equal(t, expected, SplitStatements(script, PostgreSQL), script)
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		checkSplit(t, `SELECT 'a\'; SELECT 2`, `SELECT 'a\'`, "SELECT 2")
+		checkSplit(t, `SELECT 'it''s;'; SELECT 2`, `SELECT 'it''s;'`, "SELECT 2")
+		checkSplit(t, `SELECT E'it\'s;'; SELECT 2`, `SELECT E'it\'s;'`, "SELECT 2")
+		checkSplit(t, `SELECT "a;""b"; SELECT 2`, `SELECT "a;""b"`, "SELECT 2")
+	})
+
+	t.Run("dollar quoting", func(t *testing.T) {
+		checkSplit(t, "SELECT $$a;b$$; SELECT 2", "SELECT $$a;b$$", "SELECT 2")
+		checkSplit(t, "SELECT $fn$ $$;$$ $fn$; SELECT 2", "SELECT $fn$ $$;$$ $fn$", "SELECT 2")
+		checkSplit(t, "SELECT $1; SELECT $2", "SELECT $1", "SELECT $2")
+		checkSplit(t, "SELECT a$b; SELECT 2", "SELECT a$b", "SELECT 2")
+		checkSplit(t, "SELECT $a;b", "SELECT $a", "b")
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		checkSplit(t, "SELECT /* /* ; */ ; */ 1; SELECT 2", "SELECT /* /* ; */ ; */ 1", "SELECT 2")
+		checkSplit(t, "SELECT 1 # 2; SELECT 2", "SELECT 1 # 2", "SELECT 2")
+	})
+}
+
+func TestSplitStatementsRest(t *testing.T) {
+	checkRest := func(t *testing.T, script string, statements []string, rest string) {
+		s, r := splitStatements(script, GoogleSQL)
+		require.Equal(t, statements, s, "statements")
+		require.Equal(t, rest, r, "rest")
+	}
+	checkRest(t, "SELECT 1;", []string{"SELECT 1"}, "")
+	checkRest(t, "SELECT 1; SELECT", []string{"SELECT 1"}, " SELECT")
+	checkRest(t, "SELECT ';", nil, "SELECT ';")
+	checkRest(t, "SELECT 1 -- ;", nil, "SELECT 1 -- ;")
+	checkRest(t, "BEGIN SELECT 1; SELECT 2;", nil, "BEGIN SELECT 1; SELECT 2;")
+	checkRest(t, "BEGIN", nil, "BEGIN")
+}
+
+func TestClassifyStatement(t *testing.T) {
+	checkClassify := func(sql string, keyword string, readOnly bool, destructive bool) {
+		info := classifyStatement(sql, GoogleSQL)
+		require.Equal(t, keyword, info.keyword, sql)
+		require.Equal(t, readOnly, info.isReadOnly(), sql)
+		require.Equal(t, destructive, info.isDestructive(), sql)
+	}
+	checkClassify("SELECT * FROM t", "SELECT", true, false)
+	checkClassify("-- delete everything\nselect 1", "SELECT", true, false)
+	checkClassify("/* SELECT */ DELETE FROM t WHERE id = 1", "DELETE", false, false)
+	checkClassify("# comment\nDELETE FROM t", "DELETE", false, true)
+	checkClassify("update t set a = (select max(a) from u where b = 1)", "UPDATE", false, true)
+	checkClassify("UPDATE t SET a = 1 WHERE id = 2", "UPDATE", false, false)
+	checkClassify("DELETE FROM t WHERE true", "DELETE", false, true)
+	checkClassify("DELETE FROM t WHERE (TRUE);", "DELETE", false, true)
+	checkClassify("UPDATE t SET a = 1 WHERE 1=1", "UPDATE", false, true)
+	checkClassify("update t set a = 1 where 1 = 1 then return *", "UPDATE", false, true)
+	checkClassify("DELETE FROM t WHERE 'a' = 'a'", "DELETE", false, true)
+	checkClassify("DELETE FROM t WHERE true AND id = 1", "DELETE", false, false)
+	checkClassify("DELETE FROM t WHERE 1 = 2", "DELETE", false, false)
+	checkClassify("DELETE FROM t WHERE a = a", "DELETE", false, false)
+	checkClassify("DELETE FROM t WHERE id IN (SELECT id FROM u WHERE true)", "DELETE", false, false)
+	checkClassify("WITH d AS (SELECT id FROM t WHERE x) DELETE FROM t", "DELETE", false, true)
+	checkClassify("WITH RECURSIVE a (n) AS (SELECT 1), b AS (SELECT 2) SELECT * FROM a, b", "SELECT", true, false)
+	checkClassify("(SELECT 1) UNION ALL (SELECT 2)", "SELECT", true, false)
+	checkClassify("INSERT INTO t (a) VALUES (1)", "INSERT", false, false)
+	checkClassify("drop table t", "DROP", false, true)
+	checkClassify("TRUNCATE TABLE t", "TRUNCATE", false, true)
+	checkClassify("CREATE TABLE t (id INT64) PRIMARY KEY (id)", "CREATE", false, false)
+	checkClassify("EXPLAIN DELETE FROM t", "EXPLAIN", true, false)
+	checkClassify("EXPLAIN ANALYZE DELETE FROM t WHERE true", "DELETE", false, true)
+	checkClassify("SELECT 'DELETE FROM t'", "SELECT", true, false)
+	checkClassify("CALL dataset.procedure()", "CALL", false, false)
+	checkClassify("@{USE_ADDITIONAL_PARALLELISM=TRUE} SELECT * FROM t@{FORCE_INDEX=i}", "SELECT", true, false)
+	checkClassify("", "", false, false)
+
+	require.True(t, classifyStatement("WITH x AS (SELECT 1) DELETE FROM t WHERE true", GoogleSQL).isDML())
+	require.True(t, classifyStatement("-- comment\nALTER TABLE t ADD COLUMN c INT64", GoogleSQL).isDDL())
+	require.False(t, classifyStatement("SELECT $$DROP$$", PostgreSQL).isDDL())
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		sql      string
+		dialect  Dialect
+		readOnly bool
+	}{
+		{"SELECT * FROM t", GoogleSQL, true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", GoogleSQL, true},
+		{"(SELECT 1) UNION ALL (SELECT 2)", GoogleSQL, true},
+		{"EXPLAIN SELECT * FROM t", GoogleSQL, true},
+		{"EXPLAIN DELETE FROM t WHERE true", GoogleSQL, true},
+		{"EXPLAIN ANALYZE SELECT * FROM t", GoogleSQL, true},
+		{"SHOW database.dialect", PostgreSQL, true},
+		{"GRAPH FinGraph MATCH (p:Person)-[:Owns]->(a:Account) RETURN p.name, a.id", GoogleSQL, true},
+		{"EXPLAIN ANALYZE DELETE FROM t WHERE true", GoogleSQL, false},
+		{"INSERT INTO t (a) VALUES (1)", GoogleSQL, false},
+		{"WITH x AS (SELECT 1) UPDATE t SET a = 1 WHERE true", GoogleSQL, false},
+		{"CREATE TABLE t (id INT64) PRIMARY KEY (id)", GoogleSQL, false},
+		{"CALL dataset.procedure()", GoogleSQL, false},
+		{"EXPORT DATA OPTIONS (uri = 'gs://b/*.csv', format = 'CSV') AS SELECT 1", GoogleSQL, false},
+		// BigQuery scripts may contain any statement
+		{"BEGIN DELETE FROM t WHERE true; END", GoogleSQL, false},
+		{"BEGIN DELETE FROM t WHERE true", GoogleSQL, false},
+		{"IF true THEN DELETE FROM t WHERE true; END IF", GoogleSQL, false},
+		{"DECLARE n INT64 DEFAULT (SELECT 1); DELETE FROM t WHERE id = n", GoogleSQL, false},
+		{"DECLARE n INT64", GoogleSQL, false},
+		{"SET n = (SELECT COUNT(*) FROM t)", GoogleSQL, false},
+		{"EXECUTE IMMEDIATE 'DELETE FROM t WHERE true'", GoogleSQL, false},
+		{"LOOP DELETE FROM t WHERE true; END LOOP", GoogleSQL, false},
+		// data-modifying common table expression
+		{"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", PostgreSQL, false},
+		{"SELECT $$DELETE FROM t$$", PostgreSQL, true},
+		{"", GoogleSQL, false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.readOnly, classifyStatement(test.sql, test.dialect).isReadOnly(), test.sql)
+	}
+
+	defer func() { readOnly = false }()
+	readOnly = true
+	require.NoError(t, checkReadOnly("SELECT 1", GoogleSQL))
+	require.NoError(t, checkReadOnly("BEGIN", GoogleSQL))
+	require.NoError(t, checkReadOnly("commit", GoogleSQL))
+	require.Error(t, checkReadOnly("BEGIN DELETE FROM t WHERE true; END", GoogleSQL))
+	require.Error(t, checkReadOnly("DELETE FROM t WHERE true", GoogleSQL))
+}